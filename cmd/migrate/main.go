@@ -0,0 +1,81 @@
+// Command migrate applies, rolls back or lists the schema migrations of the
+// database selected by DB_DRIVER. The server also applies pending
+// migrations when it starts.
+//
+//	go run ./cmd/migrate up|down|status
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
+	"github.com/youthtrouble/symmetrical-giggle/internal/repository"
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: migrate up|down|status")
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	logger := logger.New(cfg.LogLevel)
+
+	migrator, err := repository.NewMigrator(cfg.Database)
+	if err != nil {
+		logger.Fatal("Failed to initialize migrator", "error", err)
+	}
+	defer migrator.Close()
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			logger.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			logger.Fatal("Failed to apply migrations", "error", err)
+		}
+		logger.Info("Database is up to date", "applied", len(applied))
+
+	case "down":
+		migration, err := migrator.Down()
+		if err != nil {
+			logger.Fatal("Failed to roll back migration", "error", err)
+		}
+		if migration == nil {
+			logger.Info("No migrations to roll back")
+			return
+		}
+		logger.Info("Rolled back migration", "version", migration.Version, "name", migration.Name)
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			logger.Fatal("Failed to get migration status", "error", err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,51 @@
+// Command replay re-runs the feed parser over pages archived under
+// FEED_ARCHIVE_DIR, inserting missing reviews and backfilling empty columns.
+//
+//	go run ./cmd/replay [-app 595068606] [-dir ./feed-archive]
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
+	"github.com/youthtrouble/symmetrical-giggle/internal/repository"
+	"github.com/youthtrouble/symmetrical-giggle/internal/services"
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	appID := flag.String("app", "", "only replay pages for this app ID (default: every app)")
+	dir := flag.String("dir", cfg.Archive.Dir, "archive directory to replay")
+	flag.Parse()
+
+	logger := logger.New(cfg.LogLevel)
+	if *dir == "" {
+		logger.Fatal("No archive directory, set FEED_ARCHIVE_DIR or pass -dir")
+	}
+
+	repo, err := repository.Open(cfg.Database)
+	if err != nil {
+		logger.Fatal("Failed to initialize repository", "error", err)
+	}
+	defer repo.Close()
+
+	result, err := services.ReplayArchive(repo, services.NewRSSService(logger), services.NewFeedArchive(*dir), *appID)
+	if err != nil {
+		logger.Fatal("Failed to replay archive", "error", err)
+	}
+
+	logger.Info("Replayed archived feed pages",
+		"payloads", result.Payloads,
+		"failed", result.Failed,
+		"reviews", result.Reviews,
+		"inserted", result.Inserted,
+		"backfilled", result.Backfilled,
+		"quarantined", result.Quarantined,
+	)
+}
@@ -0,0 +1,40 @@
+// Command reprocess runs quarantined feed entries through the current parser,
+// storing any that now parse as reviews.
+//
+//	go run ./cmd/reprocess [-app 595068606]
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
+	"github.com/youthtrouble/symmetrical-giggle/internal/repository"
+	"github.com/youthtrouble/symmetrical-giggle/internal/services"
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+func main() {
+	appID := flag.String("app", "", "only reprocess entries for this app ID (default: every app)")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	logger := logger.New(cfg.LogLevel)
+
+	repo, err := repository.Open(cfg.Database)
+	if err != nil {
+		logger.Fatal("Failed to initialize repository", "error", err)
+	}
+	defer repo.Close()
+
+	result, err := services.ReprocessQuarantine(repo, logger, *appID)
+	if err != nil {
+		logger.Fatal("Failed to reprocess quarantine", "error", err)
+	}
+
+	logger.Info("Reprocessed quarantined entries", "checked", result.Checked, "recovered", result.Recovered, "failed", result.Failed)
+}
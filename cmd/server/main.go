@@ -25,14 +25,48 @@ func main() {
 
 	logger := logger.New(cfg.LogLevel)
 
-	repo, err := repository.NewSQLiteRepository(cfg.Database.Path)
+	repo, err := repository.Open(cfg.Database)
 	if err != nil {
 		logger.Fatal("Failed to initialize repository:", err)
 	}
 	defer repo.Close()
 
 	rssService := services.NewRSSService(logger)
-	pollingManager := services.NewPollingManager(repo, rssService, logger)
+	rssService.SetRequestBudget(cfg.Outbound.RequestsPerMinute, cfg.Outbound.Burst)
+	if cfg.Archive.Dir != "" {
+		archive := services.NewFeedArchive(cfg.Archive.Dir)
+		rssService.SetArchive(archive)
+		if cfg.Archive.Retention > 0 {
+			pruneCtx, stopPruning := context.WithCancel(context.Background())
+			defer stopPruning()
+			go archive.PruneEvery(pruneCtx, time.Hour, cfg.Archive.Retention, logger)
+		}
+	}
+	pollingManager := services.NewPollingManager(repo, rssService, cfg.Polling, logger)
+
+	if cfg.Sources.GooglePlayCredentials != "" {
+		creds, err := services.LoadGoogleCredentials(cfg.Sources.GooglePlayCredentials)
+		if err != nil {
+			logger.Fatal("Failed to load Google Play credentials", "error", err)
+		}
+		googlePlay, err := services.NewGooglePlayService(logger, creds)
+		if err != nil {
+			logger.Fatal("Failed to initialize Google Play source", "error", err)
+		}
+		pollingManager.AddSource(googlePlay)
+	}
+
+	if cfg.Sources.AppStoreConnectKeyPath != "" {
+		creds, err := services.LoadAppStoreConnectCredentials(cfg.Sources.AppStoreConnectKeyID, cfg.Sources.AppStoreConnectIssuerID, cfg.Sources.AppStoreConnectKeyPath)
+		if err != nil {
+			logger.Fatal("Failed to load App Store Connect key", "error", err)
+		}
+		appStoreConnect, err := services.NewAppStoreConnectService(logger, creds)
+		if err != nil {
+			logger.Fatal("Failed to initialize App Store Connect source", "error", err)
+		}
+		pollingManager.AddSource(appStoreConnect)
+	}
 
 	pollingManager.StartAll()
 	defer pollingManager.StopAll()
@@ -1,8 +1,11 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -47,23 +50,378 @@ func (h *Handlers) GetReviews(c *gin.Context) {
 		}
 	}
 
-	reviews, err := h.repo.GetReviews(appID, hours, limit)
+	country := strings.ToLower(c.Query("country"))
+	version := c.Query("version")
+
+	sortBy := c.DefaultQuery("sort", repository.SortMostRecent)
+	if sortBy != repository.SortMostRecent && sortBy != repository.SortMostHelpful {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be 'recent' or 'helpful'"})
+		return
+	}
+
+	var responded *bool
+	if r := c.Query("responded"); r != "" {
+		parsed, err := strconv.ParseBool(r)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "responded must be 'true' or 'false'"})
+			return
+		}
+		responded = &parsed
+	}
+
+	reviews, err := h.repo.QueryReviews(repository.ReviewFilter{
+		AppID:      appID,
+		Country:    country,
+		AppVersion: version,
+		Responded:  responded,
+		Hours:      hours,
+		Limit:      limit,
+		SortBy:     sortBy,
+	})
 	if err != nil {
 		h.logger.Error("Failed to get reviews", "app_id", appID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
 		return
 	}
 
+	meta := gin.H{
+		"app_id": appID,
+		"hours":  hours,
+		"count":  len(reviews),
+	}
+	if country != "" {
+		meta["country"] = country
+	}
+	if version != "" {
+		meta["version"] = version
+	}
+	if responded != nil {
+		meta["responded"] = *responded
+	}
+
+	switch c.Query("group_by") {
+	case "":
+		c.JSON(http.StatusOK, gin.H{
+			"reviews": reviews,
+			"meta":    meta,
+		})
+	case "country":
+		groups := make(map[string][]models.Review)
+		for _, review := range reviews {
+			groups[review.Country] = append(groups[review.Country], review)
+		}
+		meta["group_by"] = "country"
+		c.JSON(http.StatusOK, gin.H{
+			"groups": groups,
+			"meta":   meta,
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be 'country'"})
+	}
+}
+
+func (h *Handlers) GetVersionRatings(c *gin.Context) {
+	appID := c.Param("appId")
+	if appID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "app_id is required"})
+		return
+	}
+
+	ratings, err := h.repo.GetVersionRatings(appID)
+	if err != nil {
+		h.logger.Error("Failed to get version ratings", "app_id", appID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch version ratings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"versions": ratings,
+		"meta": gin.H{
+			"app_id": appID,
+			"count":  len(ratings),
+		},
+	})
+}
+
+func (h *Handlers) GetReviewHistory(c *gin.Context) {
+	appID := c.Param("appId")
+	reviewID := c.Param("reviewId")
+
+	review, err := h.repo.GetReview(reviewID)
+	if err != nil {
+		h.logger.Error("Failed to get review", "review_id", reviewID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review"})
+		return
+	}
+
+	if review == nil || review.AppID != appID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+
+	revisions, err := h.repo.GetReviewRevisions(reviewID)
+	if err != nil {
+		h.logger.Error("Failed to get review revisions", "review_id", reviewID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"review":    review,
+		"revisions": revisions,
+		"meta": gin.H{
+			"app_id": appID,
+			"count":  len(revisions),
+		},
+	})
+}
+
+func (h *Handlers) RecordResponse(c *gin.Context) {
+	appID := c.Param("appId")
+	reviewID := c.Param("reviewId")
+
+	var req struct {
+		Body        string     `json:"body"`
+		RespondedAt *time.Time `json:"responded_at"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body is required"})
+		return
+	}
+
+	review, err := h.repo.GetReview(reviewID)
+	if err != nil {
+		h.logger.Error("Failed to get review", "review_id", reviewID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review"})
+		return
+	}
+
+	if review == nil || review.AppID != appID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+
+	response := &models.ReviewResponse{
+		ReviewID:    reviewID,
+		AppID:       appID,
+		Body:        req.Body,
+		Source:      models.ResponseSourceManual,
+		RespondedAt: time.Now(),
+	}
+	if req.RespondedAt != nil {
+		response.RespondedAt = *req.RespondedAt
+	}
+	response.UpdatedAt = response.RespondedAt
+
+	if err := h.repo.SaveResponse(response); err != nil {
+		h.logger.Error("Failed to save response", "review_id", reviewID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save response"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"response": response})
+}
+
+func (h *Handlers) GetResponseStats(c *gin.Context) {
+	appID := c.Param("appId")
+	if appID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "app_id is required"})
+		return
+	}
+
+	days := 30 // default
+	if d := c.Query("days"); d != "" {
+		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 && parsed <= 365 {
+			days = parsed
+		}
+	}
+
+	times, err := h.repo.GetResponseTimes(appID, days)
+	if err != nil {
+		h.logger.Error("Failed to get response times", "app_id", appID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch response stats"})
+		return
+	}
+
+	report := services.BuildResponseReport(times, time.Now())
+	c.JSON(http.StatusOK, gin.H{
+		"buckets": report.Buckets,
+		"sla":     report.SLA,
+		"meta": gin.H{
+			"app_id": appID,
+			"days":   days,
+			"count":  len(times),
+		},
+	})
+}
+
+func (h *Handlers) GetApp(c *gin.Context) {
+	appID := c.Param("appId")
+	if appID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "app_id is required"})
+		return
+	}
+
+	metadata, err := h.repo.GetAppMetadata(appID)
+	if err != nil {
+		h.logger.Error("Failed to get app metadata", "app_id", appID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch app"})
+		return
+	}
+
+	if metadata == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App metadata not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"app": metadata})
+}
+
+func (h *Handlers) PollApp(c *gin.Context) {
+	appID := c.Param("appId")
+	if appID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "app_id is required"})
+		return
+	}
+
+	call, started, err := h.pollingManager.PollNow(appID)
+	if errors.Is(err, services.ErrAppNotPolled) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App is not configured for polling"})
+		return
+	}
+	if errors.Is(err, services.ErrShuttingDown) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to start poll", "app_id", appID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start poll"})
+		return
+	}
+
+	if wait, _ := strconv.ParseBool(c.Query("wait")); !wait {
+		message := "Poll started"
+		if !started {
+			message = "Poll already in progress"
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": message,
+			"started": started,
+		})
+		return
+	}
+
+	select {
+	case <-call.Done():
+	case <-c.Request.Context().Done():
+		return
+	}
+
+	if circuit := call.Skipped(); circuit != nil {
+		// The request joined a scheduled poll; a new request polls on demand,
+		// which the breaker doesn't hold back
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Scheduled poll skipped because the app's circuit is open, retry to poll on demand",
+			"circuit": circuit,
+		})
+		return
+	}
+
+	run := call.Run()
+	if run == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "App stopped being polled before the poll ran"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Poll completed",
+		"started": started,
+		"poll":    run,
+	})
+}
+
+func (h *Handlers) GetPollRuns(c *gin.Context) {
+	appID := c.Param("appId")
+	if appID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "app_id is required"})
+		return
+	}
+
+	limit := 50 // default
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	runs, err := h.repo.GetPollRuns(appID, limit)
+	if err != nil {
+		h.logger.Error("Failed to get poll runs", "app_id", appID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch poll runs"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"reviews": reviews,
+		"polls": runs,
 		"meta": gin.H{
 			"app_id": appID,
-			"hours":  hours,
-			"count":  len(reviews),
+			"count":  len(runs),
 		},
 	})
 }
 
+func (h *Handlers) GetQuarantine(c *gin.Context) {
+	appID := c.Param("appId")
+	if appID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "app_id is required"})
+		return
+	}
+
+	filter := repository.QuarantineFilter{AppID: appID, Limit: 50}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			filter.Limit = parsed
+		}
+	}
+	filter.IncludeResolved, _ = strconv.ParseBool(c.Query("include_resolved"))
+
+	entries, err := h.repo.GetQuarantinedEntries(filter)
+	if err != nil {
+		h.logger.Error("Failed to get quarantined entries", "app_id", appID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quarantined entries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"meta": gin.H{
+			"app_id": appID,
+			"count":  len(entries),
+		},
+	})
+}
+
+func (h *Handlers) ReprocessQuarantine(c *gin.Context) {
+	appID := c.Param("appId")
+	if appID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "app_id is required"})
+		return
+	}
+
+	result, err := services.ReprocessQuarantine(h.repo, h.logger, appID)
+	if err != nil {
+		h.logger.Error("Failed to reprocess quarantine", "app_id", appID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reprocess quarantined entries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reprocessed": result})
+}
+
 func (h *Handlers) ConfigureApp(c *gin.Context) {
 	appID := c.Param("appId")
 	if appID == "" {
@@ -71,9 +429,15 @@ func (h *Handlers) ConfigureApp(c *gin.Context) {
 		return
 	}
 
+	// Fields left out of the request keep their stored values
 	var req struct {
-		PollInterval string `json:"poll_interval"`
-		IsActive     *bool  `json:"is_active"`
+		PollInterval string    `json:"poll_interval"`
+		IsActive     *bool     `json:"is_active"`
+		Countries    *[]string `json:"countries"`
+		Adaptive     *bool     `json:"adaptive"`
+		FeedFormat   *string   `json:"feed_format"`
+		Platform     *string   `json:"platform"`
+		Source       *string   `json:"source"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -81,27 +445,99 @@ func (h *Handlers) ConfigureApp(c *gin.Context) {
 		return
 	}
 
+	existing, err := h.repo.GetAppConfig(appID)
+	if err != nil {
+		h.logger.Error("Failed to get app config", "app_id", appID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch configuration"})
+		return
+	}
+
+	config := &models.AppConfig{
+		AppID:        appID,
+		Platform:     models.PlatformIOS,
+		PollInterval: 5 * time.Minute, // default
+		IsActive:     true,
+		Countries:    []string{models.DefaultCountry},
+		FeedFormat:   models.FeedFormatJSON,
+	}
+	if existing != nil {
+		stored := *existing
+		config = &stored
+		if config.Platform == "" {
+			config.Platform = models.PlatformIOS
+		}
+	}
 
-	interval := 5 * time.Minute // default
 	if req.PollInterval != "" {
 		if parsed, err := time.ParseDuration(req.PollInterval); err == nil {
-			interval = parsed
+			config.PollInterval = parsed
 		} else {
 			h.logger.Error("Failed to parse poll interval", "poll_interval", req.PollInterval, "error", err)
 		}
 	}
 
-	h.logger.Info("Parsed poll interval", "input", req.PollInterval, "parsed_nanoseconds", interval.Nanoseconds())
+	h.logger.Info("Parsed poll interval", "input", req.PollInterval, "parsed_nanoseconds", config.PollInterval.Nanoseconds())
 
-	isActive := true
 	if req.IsActive != nil {
-		isActive = *req.IsActive
+		config.IsActive = *req.IsActive
 	}
 
-	config := &models.AppConfig{
-		AppID:        appID,
-		PollInterval: interval,
-		IsActive:     isActive,
+	if req.Countries != nil {
+		countries, err := normalizeCountries(*req.Countries)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		config.Countries = countries
+	}
+
+	if req.Adaptive != nil {
+		config.Adaptive = *req.Adaptive
+	}
+
+	if req.FeedFormat != nil {
+		feedFormat := strings.ToLower(strings.TrimSpace(*req.FeedFormat))
+		switch feedFormat {
+		case "":
+			feedFormat = models.FeedFormatJSON
+		case models.FeedFormatJSON, models.FeedFormatXML:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "feed_format must be 'json' or 'xml'"})
+			return
+		}
+		config.FeedFormat = feedFormat
+	}
+
+	if req.Platform != nil {
+		platform := strings.ToLower(strings.TrimSpace(*req.Platform))
+		switch platform {
+		case "":
+			platform = models.PlatformIOS
+		case models.PlatformIOS, models.PlatformAndroid:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "platform must be 'ios' or 'android'"})
+			return
+		}
+		if platform != config.Platform && req.Source == nil {
+			// The stored source was chosen for the old platform
+			config.Source = ""
+		}
+		config.Platform = platform
+	}
+
+	if !models.ValidAppID(config.Platform, appID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%q is not a valid %s app ID", appID, config.Platform)})
+		return
+	}
+
+	// An empty source polls through the platform's default
+	if req.Source != nil {
+		source := strings.ToLower(strings.TrimSpace(*req.Source))
+		if source != "" && models.SourcePlatforms[source] != config.Platform {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("source %q can't poll %s apps", source, config.Platform)})
+			return
+		}
+		config.Source = source
 	}
 
 	if err := h.repo.UpsertAppConfig(config); err != nil {
@@ -110,8 +546,8 @@ func (h *Handlers) ConfigureApp(c *gin.Context) {
 		return
 	}
 
-	if isActive {
-		h.pollingManager.StartPolling(appID, interval)
+	if config.IsActive {
+		h.pollingManager.StartPolling(config)
 	} else {
 		h.pollingManager.StopPolling(appID)
 	}
@@ -122,6 +558,28 @@ func (h *Handlers) ConfigureApp(c *gin.Context) {
 	})
 }
 
+// normalizeCountries lowercases and de-duplicates storefront codes, defaulting
+// to the US storefront when none are given.
+func normalizeCountries(input []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var countries []string
+	for _, raw := range input {
+		country := strings.ToLower(strings.TrimSpace(raw))
+		if len(country) != 2 || country[0] < 'a' || country[0] > 'z' || country[1] < 'a' || country[1] > 'z' {
+			return nil, fmt.Errorf("invalid country code %q", raw)
+		}
+		if !seen[country] {
+			seen[country] = true
+			countries = append(countries, country)
+		}
+	}
+
+	if len(countries) == 0 {
+		countries = []string{models.DefaultCountry}
+	}
+	return countries, nil
+}
+
 func (h *Handlers) GetPollingStatus(c *gin.Context) {
 	status := h.pollingManager.GetPollingStatus()
 	c.JSON(http.StatusOK, gin.H{"polling_status": status})
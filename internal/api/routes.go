@@ -18,7 +18,16 @@ func SetupRoutes(router *gin.Engine, handlers *Handlers) {
 	api := router.Group("/api")
 	{
 		api.GET("/reviews/:appId", handlers.GetReviews)
+		api.GET("/reviews/:appId/versions", handlers.GetVersionRatings)
+		api.GET("/reviews/:appId/:reviewId/history", handlers.GetReviewHistory)
+		api.POST("/reviews/:appId/:reviewId/response", handlers.RecordResponse)
+		api.GET("/apps/:appId", handlers.GetApp)
 		api.POST("/apps/:appId/configure", handlers.ConfigureApp)
+		api.GET("/apps/:appId/polls", handlers.GetPollRuns)
+		api.GET("/apps/:appId/responses/stats", handlers.GetResponseStats)
+		api.POST("/apps/:appId/poll", handlers.PollApp)
+		api.GET("/apps/:appId/quarantine", handlers.GetQuarantine)
+		api.POST("/apps/:appId/quarantine/reprocess", handlers.ReprocessQuarantine)
 		api.GET("/polling/status", handlers.GetPollingStatus)
 	}
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
 	"time"
@@ -10,6 +11,9 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	Polling  PollingConfig
+	Outbound OutboundConfig
+	Archive  ArchiveConfig
+	Sources  SourcesConfig
 	LogLevel string
 }
 
@@ -17,13 +21,65 @@ type ServerConfig struct {
 	Port string
 }
 
+// Database drivers a repository can be opened with.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// DatabaseConfig selects the repository backend. Driver defaults to Postgres
+// when URL is set and SQLite otherwise; Path is only used by SQLite and URL
+// only by Postgres.
 type DatabaseConfig struct {
-	Path string
+	Driver string
+	Path   string
+	URL    string
 }
 
 type PollingConfig struct {
 	DefaultInterval time.Duration
 	MaxConcurrent   int
+	// StartupJitter spreads the first poll of overdue apps after a restart.
+	StartupJitter time.Duration
+	// Adaptive polling bounds and the new-reviews-per-poll count at which an
+	// adaptive app's interval is shortened.
+	MinInterval       time.Duration
+	MaxInterval       time.Duration
+	AdaptiveHighWater int
+	// An app's circuit opens after BreakerThreshold consecutive failed polls
+	// and allows a trial poll once BreakerCooldown has passed.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// OutboundConfig is the request budget shared by every poller fetching from
+// Apple. A RequestsPerMinute of zero disables the budget.
+type OutboundConfig struct {
+	RequestsPerMinute int
+	Burst             int
+}
+
+// ArchiveConfig is where fetched feed pages are archived for replay. An empty
+// Dir disables archiving.
+type ArchiveConfig struct {
+	Dir string
+	// Retention is how long archived pages are kept. Zero keeps them forever.
+	Retention time.Duration
+}
+
+// SourcesConfig enables review sources beyond the App Store RSS feed, which
+// is always available.
+type SourcesConfig struct {
+	// GooglePlayCredentials is the path to a service account key file with
+	// access to the Google Play Developer API. Android apps can't be polled
+	// without it.
+	GooglePlayCredentials string
+	// AppStoreConnectKeyID, AppStoreConnectIssuerID and AppStoreConnectKeyPath
+	// identify an App Store Connect API key and the path to its .p8 file.
+	// All three are needed to poll apps through App Store Connect.
+	AppStoreConnectKeyID    string
+	AppStoreConnectIssuerID string
+	AppStoreConnectKeyPath  string
 }
 
 func Load() (*Config, error) {
@@ -32,14 +88,52 @@ func Load() (*Config, error) {
 			Port: getEnv("PORT", "4000"),
 		},
 		Database: DatabaseConfig{
-			Path: getEnv("DB_PATH", "./reviews.db"),
+			Driver: os.Getenv("DB_DRIVER"),
+			Path:   getEnv("DB_PATH", "./reviews.db"),
+			URL:    os.Getenv("DATABASE_URL"),
 		},
 		Polling: PollingConfig{
-			DefaultInterval: parseDuration(getEnv("POLL_INTERVAL", "5m")),
-			MaxConcurrent:   parseInt(getEnv("MAX_CONCURRENT_POLLS", "10")),
+			DefaultInterval:   parseDuration(getEnv("POLL_INTERVAL", "5m"), 5*time.Minute),
+			MaxConcurrent:     parseInt(getEnv("MAX_CONCURRENT_POLLS", "10"), 10),
+			StartupJitter:     parseDuration(getEnv("POLL_STARTUP_JITTER", "30s"), 30*time.Second),
+			MinInterval:       parseDuration(getEnv("POLL_MIN_INTERVAL", "1m"), time.Minute),
+			MaxInterval:       parseDuration(getEnv("POLL_MAX_INTERVAL", "6h"), 6*time.Hour),
+			AdaptiveHighWater: parseInt(getEnv("POLL_ADAPTIVE_HIGH_WATER", "20"), 20),
+			BreakerThreshold:  parseInt(getEnv("BREAKER_FAILURE_THRESHOLD", "5"), 5),
+			BreakerCooldown:   parseDuration(getEnv("BREAKER_COOLDOWN", "30m"), 30*time.Minute),
+		},
+		Outbound: OutboundConfig{
+			RequestsPerMinute: parseInt(getEnv("OUTBOUND_REQUESTS_PER_MINUTE", "120"), 120),
+			Burst:             parseInt(getEnv("OUTBOUND_BURST", "10"), 10),
+		},
+		Archive: ArchiveConfig{
+			Dir:       os.Getenv("FEED_ARCHIVE_DIR"),
+			Retention: parseDuration(os.Getenv("FEED_ARCHIVE_RETENTION"), 0),
+		},
+		Sources: SourcesConfig{
+			GooglePlayCredentials:   os.Getenv("GOOGLE_PLAY_CREDENTIALS"),
+			AppStoreConnectKeyID:    os.Getenv("APP_STORE_CONNECT_KEY_ID"),
+			AppStoreConnectIssuerID: os.Getenv("APP_STORE_CONNECT_ISSUER_ID"),
+			AppStoreConnectKeyPath:  os.Getenv("APP_STORE_CONNECT_KEY_PATH"),
 		},
 		LogLevel: getEnv("LOG_LEVEL", "info"),
 	}
+
+	switch cfg.Database.Driver {
+	case "":
+		cfg.Database.Driver = DriverSQLite
+		if cfg.Database.URL != "" {
+			cfg.Database.Driver = DriverPostgres
+		}
+	case DriverSQLite:
+	case DriverPostgres:
+		if cfg.Database.URL == "" {
+			return nil, fmt.Errorf("DATABASE_URL is required with DB_DRIVER=%s", DriverPostgres)
+		}
+	default:
+		return nil, fmt.Errorf("unknown DB_DRIVER %q, expected %q or %q", cfg.Database.Driver, DriverSQLite, DriverPostgres)
+	}
+
 	return cfg, nil
 }
 
@@ -50,18 +144,18 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func parseDuration(s string) time.Duration {
+func parseDuration(s string, fallback time.Duration) time.Duration {
 	d, err := time.ParseDuration(s)
 	if err != nil {
-		return 5 * time.Minute
+		return fallback
 	}
 	return d
 }
 
-func parseInt(s string) int {
+func parseInt(s string, fallback int) int {
 	i, err := strconv.Atoi(s)
 	if err != nil {
-		return 10
+		return fallback
 	}
 	return i
 }
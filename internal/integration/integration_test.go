@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/suite"
 
 	"github.com/youthtrouble/symmetrical-giggle/internal/api"
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
 	"github.com/youthtrouble/symmetrical-giggle/internal/models"
 	"github.com/youthtrouble/symmetrical-giggle/internal/repository"
 	"github.com/youthtrouble/symmetrical-giggle/internal/services"
@@ -39,7 +40,7 @@ func (s *IntegrationTestSuite) SetupSuite() {
 
 	logger := logger.New("error")
 	rssService := services.NewRSSService(logger)
-	pollingManager := services.NewPollingManager(repo, rssService, logger)
+	pollingManager := services.NewPollingManager(repo, rssService, config.PollingConfig{MaxConcurrent: 2}, logger)
 
 	s.handlers = api.NewHandlers(repo, pollingManager, logger)
 
@@ -103,6 +104,141 @@ func (s *IntegrationTestSuite) TestConfigureAppEndpoint() {
 	s.Assert().Equal("Configuration updated successfully", response["message"])
 }
 
+func (s *IntegrationTestSuite) TestConfigureAppKeepsStoredFields() {
+	configure := func(appID string, body map[string]interface{}) models.AppConfig {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/api/apps/"+appID+"/configure", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Config models.AppConfig `json:"config"`
+		}
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Config
+	}
+
+	configure("777777", map[string]interface{}{
+		"poll_interval": "10m",
+		"is_active":     false,
+		"countries":     []string{"gb", "de"},
+		"adaptive":      true,
+		"feed_format":   "xml",
+	})
+
+	// Changing one field leaves the others as they were, and doesn't
+	// reactivate the app
+	config := configure("777777", map[string]interface{}{"poll_interval": "20m"})
+	s.Assert().Equal(20*time.Minute, config.PollInterval)
+	s.Assert().False(config.IsActive)
+	s.Assert().Equal([]string{"gb", "de"}, config.Countries)
+	s.Assert().True(config.Adaptive)
+	s.Assert().Equal(models.FeedFormatXML, config.FeedFormat)
+	s.Assert().Equal(models.PlatformIOS, config.Platform)
+
+	stored, err := s.repo.GetAppConfig("777777")
+	s.Require().NoError(err)
+	s.Assert().Equal([]string{"gb", "de"}, stored.Countries)
+	s.Assert().Equal(models.FeedFormatXML, stored.FeedFormat)
+
+	configure("com.example.app", map[string]interface{}{"platform": "android", "is_active": false})
+	config = configure("com.example.app", map[string]interface{}{"poll_interval": "1h"})
+	s.Assert().Equal(models.PlatformAndroid, config.Platform)
+	s.Assert().False(config.IsActive)
+}
+
+func (s *IntegrationTestSuite) TestConfigureAppRejectsInvalidAppID() {
+	for appID, platform := range map[string]string{
+		"..":              "ios",
+		"com.example.app": "ios",
+		"123456":          "android",
+		"com..example":    "android",
+	} {
+		jsonData, _ := json.Marshal(map[string]interface{}{"platform": platform, "is_active": false})
+		req, _ := http.NewRequest("POST", "/api/apps/"+appID+"/configure", bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Assert().Equal(http.StatusBadRequest, w.Code, "%s on %s", appID, platform)
+	}
+}
+
+func (s *IntegrationTestSuite) TestReviewHistoryEndpoint() {
+	review := &models.Review{
+		ID:            "history-review",
+		AppID:         "123456",
+		Author:        "Test User",
+		Rating:        2,
+		Content:       "Meh",
+		SubmittedDate: time.Now(),
+		CreatedAt:     time.Now(),
+	}
+	_, err := s.repo.SaveReview(review)
+	s.Require().NoError(err)
+
+	review.Rating = 5
+	review.Content = "Much better now"
+	_, err = s.repo.SaveReview(review)
+	s.Require().NoError(err)
+
+	req, _ := http.NewRequest("GET", "/api/reviews/123456/history-review/history", nil)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	s.Assert().Equal(http.StatusOK, w.Code)
+
+	var response struct {
+		Review    models.Review           `json:"review"`
+		Revisions []models.ReviewRevision `json:"revisions"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	s.Require().NoError(err)
+
+	s.Assert().Equal(5, response.Review.Rating)
+	s.Require().Len(response.Revisions, 1)
+	s.Assert().Equal("Meh", response.Revisions[0].Content)
+
+	req, _ = http.NewRequest("GET", "/api/reviews/999999/history-review/history", nil)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	s.Assert().Equal(http.StatusNotFound, w.Code)
+}
+
+func (s *IntegrationTestSuite) TestGetAppEndpoint() {
+	req, _ := http.NewRequest("GET", "/api/apps/777777", nil)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	s.Assert().Equal(http.StatusNotFound, w.Code)
+
+	err := s.repo.UpsertAppMetadata(&models.AppMetadata{
+		AppID:     "777777",
+		Name:      "Example App",
+		Artist:    "Example Inc.",
+		UpdatedAt: time.Now(),
+	})
+	s.Require().NoError(err)
+
+	req, _ = http.NewRequest("GET", "/api/apps/777777", nil)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	s.Assert().Equal(http.StatusOK, w.Code)
+
+	var response struct {
+		App models.AppMetadata `json:"app"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	s.Require().NoError(err)
+
+	s.Assert().Equal("Example App", response.App.Name)
+}
+
 func (s *IntegrationTestSuite) TestHealthCheckEndpoint() {
 	req, _ := http.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
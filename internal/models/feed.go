@@ -0,0 +1,212 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// The customer-reviews JSON feed is a mechanical translation of Apple's Atom
+// feed, and its shape drifts with the number of entries: a one-element list is
+// served as a bare object, empty lists are omitted, and labels are sometimes
+// numbers rather than strings. The types below accept all of these so a page
+// only fails to decode when it isn't JSON at all.
+
+type RSSFeed struct {
+	Feed struct {
+		Entry OneOrMany[RSSEntry] `json:"entry"`
+	} `json:"feed"`
+}
+
+type RSSEntry struct {
+	ID     RSSID `json:"id"`
+	Author struct {
+		Name RSSLabel `json:"name"`
+		URI  RSSLabel `json:"uri"`
+	} `json:"author"`
+	Rating    RSSLabel `json:"im:rating"`
+	Title     RSSLabel `json:"title"`
+	Content   RSSLabel `json:"content"`
+	Updated   RSSLabel `json:"updated"`
+	Version   RSSLabel `json:"im:version"`
+	VoteSum   RSSLabel `json:"im:voteSum"`
+	VoteCount RSSLabel `json:"im:voteCount"`
+	Link      RSSLink  `json:"link"`
+
+	// App metadata, only present on the feed's leading entry.
+	Name     RSSLabel            `json:"im:name"`
+	Artist   RSSLabel            `json:"im:artist"`
+	Image    OneOrMany[RSSImage] `json:"im:image"`
+	Category struct {
+		Attributes struct {
+			Label string `json:"label"`
+		} `json:"attributes"`
+	} `json:"category"`
+
+	// Raw is the entry exactly as served, kept for quarantining entries the
+	// parser rejects.
+	Raw json.RawMessage `json:"-"`
+}
+
+func (e *RSSEntry) UnmarshalJSON(data []byte) error {
+	type plain RSSEntry
+	if err := json.Unmarshal(data, (*plain)(e)); err != nil {
+		return err
+	}
+	e.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// RSSLabel is a feed field of the form {"label": "..."}. A bare value is
+// taken as the label itself.
+type RSSLabel struct {
+	Label string `json:"label"`
+}
+
+func (l *RSSLabel) UnmarshalJSON(data []byte) error {
+	if !isObject(data) {
+		l.Label = scalarText(data)
+		return nil
+	}
+
+	var aux struct {
+		Label json.RawMessage `json:"label"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	l.Label = scalarText(aux.Label)
+	return nil
+}
+
+// RSSID is an entry's id. On the metadata entry its attributes carry the
+// app's numeric ID and bundle ID.
+type RSSID struct {
+	Label      string `json:"label"`
+	Attributes struct {
+		ID       string `json:"im:id"`
+		BundleID string `json:"im:bundleId"`
+	} `json:"attributes"`
+}
+
+func (id *RSSID) UnmarshalJSON(data []byte) error {
+	if !isObject(data) {
+		id.Label = scalarText(data)
+		return nil
+	}
+
+	type plain RSSID
+	var aux struct {
+		plain
+		Label json.RawMessage `json:"label"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*id = RSSID(aux.plain)
+	id.Label = scalarText(aux.Label)
+	return nil
+}
+
+// RSSLink is an entry's link. When a list of links is served the first one
+// with an href is kept.
+type RSSLink struct {
+	Attributes struct {
+		Rel  string `json:"rel"`
+		Href string `json:"href"`
+	} `json:"attributes"`
+}
+
+func (l *RSSLink) UnmarshalJSON(data []byte) error {
+	type plain RSSLink
+	var links OneOrMany[plain]
+	if err := json.Unmarshal(data, &links); err != nil {
+		return err
+	}
+
+	*l = RSSLink{}
+	for _, link := range links {
+		if link.Attributes.Href != "" {
+			*l = RSSLink(link)
+			return nil
+		}
+	}
+	return nil
+}
+
+// RSSImage is one size of the app icon listed on the metadata entry.
+type RSSImage struct {
+	Label      string `json:"label"`
+	Attributes struct {
+		Height string `json:"height"`
+	} `json:"attributes"`
+}
+
+func (img *RSSImage) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Label      json.RawMessage `json:"label"`
+		Attributes struct {
+			Height json.RawMessage `json:"height"`
+		} `json:"attributes"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	img.Label = scalarText(aux.Label)
+	img.Attributes.Height = scalarText(aux.Attributes.Height)
+	return nil
+}
+
+// OneOrMany decodes a JSON array, or a single object served in place of a
+// one-element array. A missing or null value decodes to an empty list.
+type OneOrMany[T any] []T
+
+func (m *OneOrMany[T]) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = nil
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '[' {
+		var items []T
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		*m = items
+		return nil
+	}
+
+	var item T
+	if err := json.Unmarshal(data, &item); err != nil {
+		return err
+	}
+	*m = OneOrMany[T]{item}
+	return nil
+}
+
+func isObject(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '{'
+}
+
+// scalarText renders a JSON string, number or boolean as text. Anything else,
+// including null, is treated as empty.
+func scalarText(data []byte) string {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return ""
+	}
+
+	switch data[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return ""
+		}
+		return s
+	case '{', '[', 'n':
+		return ""
+	default:
+		return string(data)
+	}
+}
@@ -1,52 +1,224 @@
 package models
 
 import (
+	"regexp"
 	"time"
 )
 
+// DefaultCountry is the App Store storefront polled when an app has no
+// storefronts configured.
+const DefaultCountry = "us"
+
+// Platforms an app's reviews can come from.
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+)
+
+// Sources an app's reviews can be fetched through.
+const (
+	// SourceAppStoreRSS is Apple's public customer-reviews feed, available
+	// for any iOS app.
+	SourceAppStoreRSS = "app_store_rss"
+	// SourceAppStoreConnect is the App Store Connect API, which only serves
+	// our own apps but includes developer responses and full history.
+	SourceAppStoreConnect = "app_store_connect"
+	SourceGooglePlay      = "google_play"
+)
+
+// SourcePlatforms maps each source to the platform whose apps it serves.
+var SourcePlatforms = map[string]string{
+	SourceAppStoreRSS:     PlatformIOS,
+	SourceAppStoreConnect: PlatformIOS,
+	SourceGooglePlay:      PlatformAndroid,
+}
+
+// DefaultSource returns the source polled for a platform's apps when none is
+// configured.
+func DefaultSource(platform string) string {
+	if platform == PlatformAndroid {
+		return SourceGooglePlay
+	}
+	return SourceAppStoreRSS
+}
+
+var (
+	iosAppID     = regexp.MustCompile(`^[0-9]+$`)
+	androidAppID = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z][A-Za-z0-9_]*)+$`)
+)
+
+// ValidAppID reports whether appID has the shape of an app ID on platform:
+// numeric for the App Store, a Java-style package name for Google Play.
+func ValidAppID(platform, appID string) bool {
+	if platform == PlatformAndroid {
+		return androidAppID.MatchString(appID)
+	}
+	return iosAppID.MatchString(appID)
+}
+
 type Review struct {
 	ID            string    `json:"id" db:"id"`
 	AppID         string    `json:"app_id" db:"app_id"`
+	Platform      string    `json:"platform" db:"platform"`
+	Country       string    `json:"country" db:"country"`
 	Author        string    `json:"author" db:"author"`
 	Rating        int       `json:"rating" db:"rating"`
 	Title         *string   `json:"title" db:"title"`
 	Content       string    `json:"content" db:"content"`
+	AppVersion    string    `json:"app_version" db:"app_version"`
+	VoteSum       int       `json:"vote_sum" db:"vote_sum"`
+	VoteCount     int       `json:"vote_count" db:"vote_count"`
+	AuthorURI     string    `json:"author_uri" db:"author_uri"`
+	ReviewURL     string    `json:"review_url" db:"review_url"`
 	SubmittedDate time.Time `json:"submitted_date" db:"submitted_date"`
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	// DeveloperResponse is our published reply, as reported by sources that
+	// report it or recorded through the API.
+	DeveloperResponse     *string    `json:"developer_response,omitempty" db:"developer_response"`
+	DeveloperResponseDate *time.Time `json:"developer_response_date,omitempty" db:"developer_response_date"`
+	// Responded reports whether a ReviewResponse is recorded for the review.
+	Responded bool `json:"responded" db:"responded"`
+}
+
+// ResponseSourceManual marks a response entered through the API rather than
+// synced from a review source.
+const ResponseSourceManual = "manual"
+
+// ReviewResponse is our reply to a review. A review has at most one.
+type ReviewResponse struct {
+	ReviewID string `json:"review_id" db:"review_id"`
+	AppID    string `json:"app_id" db:"app_id"`
+	Body     string `json:"body" db:"body"`
+	// Source is the review source the reply was synced from, or
+	// ResponseSourceManual.
+	Source string `json:"source" db:"source"`
+	// RespondedAt is when the reviewer first got an answer; editing the
+	// reply later doesn't move it.
+	RespondedAt time.Time `json:"responded_at" db:"responded_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ReviewResponseTime pairs a review with when it was answered, if it was.
+type ReviewResponseTime struct {
+	ReviewID      string     `db:"review_id"`
+	Rating        int        `db:"rating"`
+	SubmittedDate time.Time  `db:"submitted_date"`
+	RespondedAt   *time.Time `db:"responded_at"`
+}
+
+// ReviewRevision is a previous version of a review, recorded when the author
+// edits the text or changes the rating.
+type ReviewRevision struct {
+	ID            int64     `json:"id" db:"id"`
+	ReviewID      string    `json:"review_id" db:"review_id"`
+	Rating        int       `json:"rating" db:"rating"`
+	Title         *string   `json:"title" db:"title"`
+	Content       string    `json:"content" db:"content"`
+	AppVersion    string    `json:"app_version" db:"app_version"`
+	SubmittedDate time.Time `json:"submitted_date" db:"submitted_date"`
+	RevisedAt     time.Time `json:"revised_at" db:"revised_at"`
 }
 
+// VersionRating summarises the reviews left against a single app version.
+type VersionRating struct {
+	AppVersion    string  `json:"app_version" db:"app_version"`
+	Count         int     `json:"count" db:"count"`
+	AverageRating float64 `json:"average_rating" db:"average_rating"`
+	VoteSum       int     `json:"vote_sum" db:"vote_sum"`
+}
+
+// Feed formats Apple serves the customer-reviews feed in.
+const (
+	FeedFormatJSON = "json"
+	FeedFormatXML  = "xml"
+)
+
 type AppConfig struct {
 	AppID        string        `json:"app_id" db:"app_id"`
+	Platform     string        `json:"platform" db:"platform"`
 	PollInterval time.Duration `json:"poll_interval" db:"poll_interval"`
 	LastPoll     *time.Time    `json:"last_poll" db:"last_poll"`
 	IsActive     bool          `json:"is_active" db:"is_active"`
+	Countries    []string      `json:"countries" db:"-"`
+	// Adaptive lets the poller shorten or stretch PollInterval based on how
+	// many new reviews each poll finds.
+	Adaptive bool `json:"adaptive" db:"adaptive"`
+	// FeedFormat selects the JSON or XML feed; JSON when empty.
+	FeedFormat string `json:"feed_format" db:"feed_format"`
+	// Source selects how the platform's reviews are fetched; the platform's
+	// DefaultSource when empty.
+	Source string `json:"source" db:"source"`
+}
+
+// AppMetadata describes an app as listed in its storefront, taken from the
+// leading entry of the reviews feed.
+type AppMetadata struct {
+	AppID     string    `json:"app_id" db:"app_id"`
+	Name      string    `json:"name" db:"name"`
+	Artist    string    `json:"artist" db:"artist"`
+	BundleID  string    `json:"bundle_id" db:"bundle_id"`
+	IconURL   string    `json:"icon_url" db:"icon_url"`
+	Category  string    `json:"category" db:"category"`
+	StoreURL  string    `json:"store_url" db:"store_url"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PollRun records the outcome of a single poll of an app.
+type PollRun struct {
+	ID         int64     `json:"id" db:"id"`
+	AppID      string    `json:"app_id" db:"app_id"`
+	StartedAt  time.Time `json:"started_at" db:"started_at"`
+	DurationMs int64     `json:"duration_ms" db:"duration_ms"`
+	Attempts   int       `json:"attempts" db:"attempts"`
+	HTTPStatus int       `json:"http_status" db:"http_status"`
+	Pages      int       `json:"pages" db:"pages"`
+	Fetched    int       `json:"fetched" db:"fetched"`
+	Stored     int       `json:"stored" db:"stored"`
+	Updated    int       `json:"updated" db:"updated"`
+	Error      *string   `json:"error" db:"error"`
+	// StorefrontErrors lists storefronts that failed in a run that still
+	// fetched the others.
+	StorefrontErrors *string `json:"storefront_errors" db:"storefront_errors"`
+}
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitBreaker is the persisted breaker state guarding an app's feed.
+type CircuitBreaker struct {
+	AppID     string     `json:"app_id" db:"app_id"`
+	State     string     `json:"state" db:"state"`
+	Failures  int        `json:"failures" db:"failures"`
+	OpenedAt  *time.Time `json:"opened_at" db:"opened_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// FeedCache holds the cache validators Apple last served for an app's feed
+// in one storefront.
+type FeedCache struct {
+	AppID        string    `json:"app_id" db:"app_id"`
+	Country      string    `json:"country" db:"country"`
+	ETag         string    `json:"etag" db:"etag"`
+	LastModified string    `json:"last_modified" db:"last_modified"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
 }
 
-type RSSFeed struct {
-	Feed struct {
-		Entry []RSSEntry `json:"entry"`
-	} `json:"feed"`
-}
-
-type RSSEntry struct {
-	ID struct {
-		Label string `json:"label"`
-	} `json:"id"`
-	Author struct {
-		Name struct {
-			Label string `json:"label"`
-		} `json:"name"`
-	} `json:"author"`
-	Rating struct {
-		Label string `json:"label"`
-	} `json:"im:rating"`
-	Title struct {
-		Label string `json:"label"`
-	} `json:"title"`
-	Content struct {
-		Label string `json:"label"`
-	} `json:"content"`
-	Updated struct {
-		Label string `json:"label"`
-	} `json:"updated"`
+// QuarantinedEntry is a feed entry the parser rejected, kept verbatim so it
+// can be reprocessed once the parser is fixed.
+type QuarantinedEntry struct {
+	ID      int64  `json:"id" db:"id"`
+	AppID   string `json:"app_id" db:"app_id"`
+	Country string `json:"country" db:"country"`
+	EntryID string `json:"entry_id" db:"entry_id"`
+	Reason  string `json:"reason" db:"reason"`
+	Payload string `json:"payload" db:"payload"`
+	// Checksum identifies the payload so a rejected entry seen on every poll
+	// is only stored once.
+	Checksum      string     `json:"-" db:"checksum"`
+	QuarantinedAt time.Time  `json:"quarantined_at" db:"quarantined_at"`
+	ResolvedAt    *time.Time `json:"resolved_at" db:"resolved_at"`
 }
@@ -1,17 +1,145 @@
 package repository
 
 import (
+	"context"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
 	"github.com/youthtrouble/symmetrical-giggle/internal/models"
 )
 
+// ReviewFilter narrows a review query. Zero-valued fields are not applied.
+type ReviewFilter struct {
+	AppID      string
+	Country    string
+	AppVersion string
+	// Responded, when set, keeps only reviews that were (or weren't) answered.
+	Responded *bool
+	Hours     int
+	Limit     int
+	// SortBy orders results; SortMostRecent is used when empty.
+	SortBy string
+}
+
+const (
+	SortMostRecent  = "recent"
+	SortMostHelpful = "helpful"
+)
+
+// QuarantineFilter narrows a quarantine listing. Zero-valued fields are not applied.
+type QuarantineFilter struct {
+	AppID string
+	// IncludeResolved also lists entries that have since been reprocessed.
+	IncludeResolved bool
+	Limit           int
+}
+
+// ReviewChange describes what SaveReview did with a fetched review.
+type ReviewChange int
+
+const (
+	ReviewUnchanged ReviewChange = iota
+	ReviewInserted
+	ReviewUpdated
+)
+
 type Repository interface {
 	CreateReview(review *models.Review) error
+	// CreateReviews inserts the reviews that aren't stored yet in a single
+	// transaction and returns how many were new. Stored reviews are left
+	// untouched.
+	CreateReviews(ctx context.Context, reviews []models.Review) (inserted int, err error)
+	// SaveReview inserts a new review, or records the stored version as a
+	// revision and updates it when the title, content or rating has changed.
+	SaveReview(review *models.Review) (ReviewChange, error)
+	// BackfillReview inserts a review that isn't stored yet, or fills in
+	// columns of the stored one that are still empty. Existing values are
+	// never overwritten.
+	BackfillReview(review *models.Review) (ReviewChange, error)
+	GetReview(id string) (*models.Review, error)
+	GetReviewRevisions(reviewID string) ([]models.ReviewRevision, error)
 	GetReviews(appID string, hours int, limit int) ([]models.Review, error)
+	QueryReviews(filter ReviewFilter) ([]models.Review, error)
 	ReviewExists(id string) (bool, error)
+	// StoredReviewIDs reports which of ids are already stored.
+	StoredReviewIDs(ids []string) (map[string]bool, error)
+	GetVersionRatings(appID string) ([]models.VersionRating, error)
+	CountReviewsByApp() (map[string]int, error)
+
+	// SaveResponse records our reply to a review and copies it onto the
+	// review's developer response, dated UpdatedAt. Replying again replaces
+	// the body and source but keeps the earliest RespondedAt.
+	SaveResponse(response *models.ReviewResponse) error
+	GetResponse(reviewID string) (*models.ReviewResponse, error)
+	// GetResponseTimes lists an app's reviews submitted in the last days, with
+	// when each was answered.
+	GetResponseTimes(appID string, days int) ([]models.ReviewResponseTime, error)
 
 	GetAppConfig(appID string) (*models.AppConfig, error)
 	UpsertAppConfig(config *models.AppConfig) error
+	UpdateLastPoll(appID string, polledAt time.Time) error
 	GetActiveApps() ([]string, error)
 
+	UpsertAppMetadata(metadata *models.AppMetadata) error
+	GetAppMetadata(appID string) (*models.AppMetadata, error)
+
+	CreatePollRun(run *models.PollRun) error
+	GetPollRuns(appID string, limit int) ([]models.PollRun, error)
+
+	GetCircuitBreaker(appID string) (*models.CircuitBreaker, error)
+	UpsertCircuitBreaker(breaker *models.CircuitBreaker) error
+
+	GetFeedCache(appID string) ([]models.FeedCache, error)
+	UpsertFeedCache(entry *models.FeedCache) error
+
+	// QuarantineEntry stores a rejected feed entry, ignoring one already held
+	// with the same checksum.
+	QuarantineEntry(entry *models.QuarantinedEntry) error
+	GetQuarantinedEntries(filter QuarantineFilter) ([]models.QuarantinedEntry, error)
+	ResolveQuarantinedEntry(id int64, resolvedAt time.Time) error
+
+	// BeginTx starts a transaction for writes that must land together.
+	// SQLite allows one connection, so nothing else may use the repository
+	// until it is committed or rolled back.
+	BeginTx(ctx context.Context) (Tx, error)
+
 	Close() error
 }
+
+// Tx is the part of Repository that can be written in one transaction, such
+// as a poll's reviews and the app's last poll time.
+type Tx interface {
+	CreateReviews(ctx context.Context, reviews []models.Review) (inserted int, err error)
+	SaveReview(review *models.Review) (ReviewChange, error)
+	// StoredReviews loads the stored versions of ids, keyed by ID. Missing
+	// ones are new.
+	StoredReviews(ids []string) (map[string]models.Review, error)
+	// SaveResponse records a reply as Repository.SaveResponse does.
+	SaveResponse(response *models.ReviewResponse) error
+	// ClearResponse removes a reply synced from source that the source no
+	// longer reports, leaving replies from elsewhere alone. It reports
+	// whether anything was removed.
+	ClearResponse(reviewID, source string) (bool, error)
+	UpdateLastPoll(appID string, polledAt time.Time) error
+
+	Commit() error
+	// Rollback discards the transaction; deferring it after Commit is harmless.
+	Rollback() error
+}
+
+// Open connects to the database cfg.Driver selects.
+func Open(cfg config.DatabaseConfig) (Repository, error) {
+	if cfg.Driver == config.DriverPostgres {
+		repo, err := NewPostgresRepository(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	}
+
+	repo, err := NewSQLiteRepository(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
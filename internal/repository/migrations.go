@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
+)
+
+// Migrations live under migrations/<driver> as NNNN_name.up.sql and
+// NNNN_name.down.sql. Applied versions are recorded in schema_migrations.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationLockID serialises Postgres replicas migrating at the same time.
+const migrationLockID = 4708215
+
+// Migration is one numbered schema change.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus is a migration and, when it has been applied, when.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies and rolls back a database's schema migrations.
+type Migrator struct {
+	db         *sqlx.DB
+	driver     string
+	migrations []Migration
+}
+
+// NewMigrator connects to the database cfg selects without changing its
+// schema.
+func NewMigrator(cfg config.DatabaseConfig) (*Migrator, error) {
+	var db *sqlx.DB
+	var err error
+	if cfg.Driver == config.DriverPostgres {
+		db, err = sqlx.Open("postgres", cfg.URL)
+	} else {
+		db, err = sqlx.Open("sqlite3", cfg.Path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if cfg.Driver != config.DriverPostgres {
+		db.SetMaxOpenConns(1)
+	}
+
+	m, err := newMigrator(db, cfg.Driver)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func newMigrator(db *sqlx.DB, driver string) (*Migrator, error) {
+	if driver == "" {
+		driver = config.DriverSQLite
+	}
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return &Migrator{db: db, driver: driver, migrations: migrations}, nil
+}
+
+func loadMigrations(driver string) ([]Migration, error) {
+	dir := "migrations/" + driver
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected file %s/%s", dir, entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := fs.ReadFile(migrationFiles, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.up = string(data)
+		} else {
+			migration.down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns those it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.init(); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if m.driver == config.DriverSQLite && len(applied) == 0 {
+		if err := upgradeLegacySQLite(m.db); err != nil {
+			return nil, err
+		}
+	}
+
+	var ran []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		done, err := m.run(migration, true)
+		if err != nil {
+			return ran, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		if done {
+			ran = append(ran, migration)
+		}
+	}
+	return ran, nil
+}
+
+// Down rolls back the latest applied migration, returning nil when none is
+// applied.
+func (m *Migrator) Down() (*Migration, error) {
+	if err := m.init(); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if _, err := m.run(migration, false); err != nil {
+			return nil, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		return &migration, nil
+	}
+	return nil, nil
+}
+
+// Status lists every known migration and when it was applied.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if err := m.init(); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+func (m *Migrator) init() error {
+	appliedAt := "DATETIME"
+	if m.driver == config.DriverPostgres {
+		appliedAt = "TIMESTAMPTZ"
+	}
+
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.lock(tx); err != nil {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at %s NOT NULL
+		)
+	`, appliedAt))
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	var rows []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := m.db.Select(&rows, "SELECT version, applied_at FROM schema_migrations"); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+// run applies (or, when up is false, rolls back) migration in one
+// transaction with its schema_migrations row. It reports false without
+// running anything when another process got there first.
+func (m *Migrator) run(migration Migration, up bool) (bool, error) {
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if err := m.lock(tx); err != nil {
+		return false, err
+	}
+
+	// Up and Down check what's applied before taking the lock, so look again
+	var count int
+	if err := tx.Get(&count, m.db.Rebind("SELECT COUNT(*) FROM schema_migrations WHERE version = ?"), migration.Version); err != nil {
+		return false, err
+	}
+	if applied := count > 0; applied == up {
+		return false, nil
+	}
+
+	if up {
+		if _, err := tx.Exec(migration.up); err != nil {
+			return false, err
+		}
+		_, err = tx.Exec(m.db.Rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"), migration.Version, migration.Name, time.Now())
+	} else {
+		if _, err := tx.Exec(migration.down); err != nil {
+			return false, err
+		}
+		_, err = tx.Exec(m.db.Rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// lock holds a Postgres advisory lock until tx ends. SQLite needs none, as
+// it only allows one writer.
+func (m *Migrator) lock(tx *sqlx.Tx) error {
+	if m.driver != config.DriverPostgres {
+		return nil
+	}
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockID)
+	return err
+}
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS apps;
+DROP TABLE IF EXISTS review_responses;
+DROP TABLE IF EXISTS quarantined_entries;
+DROP TABLE IF EXISTS feed_cache;
+DROP TABLE IF EXISTS circuit_breakers;
+DROP TABLE IF EXISTS poll_runs;
+DROP TABLE IF EXISTS review_revisions;
+DROP TABLE IF EXISTS app_configs;
+DROP TABLE IF EXISTS reviews;
@@ -0,0 +1,129 @@
+CREATE TABLE IF NOT EXISTS reviews (
+	id TEXT PRIMARY KEY,
+	app_id TEXT NOT NULL,
+	platform TEXT NOT NULL DEFAULT 'ios',
+	country TEXT NOT NULL DEFAULT 'us',
+	author TEXT NOT NULL,
+	rating INTEGER NOT NULL,
+	title TEXT,
+	content TEXT NOT NULL,
+	app_version TEXT NOT NULL DEFAULT '',
+	vote_sum INTEGER NOT NULL DEFAULT 0,
+	vote_count INTEGER NOT NULL DEFAULT 0,
+	author_uri TEXT NOT NULL DEFAULT '',
+	review_url TEXT NOT NULL DEFAULT '',
+	submitted_date TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	developer_response TEXT,
+	developer_response_date TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_reviews_app_date ON reviews(app_id, submitted_date DESC);
+CREATE INDEX IF NOT EXISTS idx_reviews_rating ON reviews(app_id, rating DESC);
+CREATE INDEX IF NOT EXISTS idx_reviews_app_country_date ON reviews(app_id, country, submitted_date DESC);
+CREATE INDEX IF NOT EXISTS idx_reviews_app_version ON reviews(app_id, app_version);
+
+CREATE TABLE IF NOT EXISTS app_configs (
+	app_id TEXT PRIMARY KEY,
+	poll_interval BIGINT DEFAULT 300000000000, -- nanoseconds (5 minutes = 300000000000 ns)
+	last_poll TIMESTAMPTZ,
+	is_active BOOLEAN DEFAULT TRUE,
+	countries TEXT NOT NULL DEFAULT 'us', -- comma-separated storefront codes
+	adaptive BOOLEAN NOT NULL DEFAULT FALSE,
+	feed_format TEXT NOT NULL DEFAULT 'json',
+	platform TEXT NOT NULL DEFAULT 'ios',
+	source TEXT NOT NULL DEFAULT '' -- empty for the platform's default source
+);
+
+CREATE TABLE IF NOT EXISTS review_revisions (
+	id BIGSERIAL PRIMARY KEY,
+	review_id TEXT NOT NULL,
+	rating INTEGER NOT NULL,
+	title TEXT,
+	content TEXT NOT NULL,
+	app_version TEXT NOT NULL DEFAULT '',
+	submitted_date TIMESTAMPTZ NOT NULL,
+	revised_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_revisions_review ON review_revisions(review_id, revised_at DESC);
+
+CREATE TABLE IF NOT EXISTS poll_runs (
+	id BIGSERIAL PRIMARY KEY,
+	app_id TEXT NOT NULL,
+	started_at TIMESTAMPTZ NOT NULL,
+	duration_ms BIGINT NOT NULL,
+	-- Every request sent for the poll, retries included; one per page when
+	-- nothing failed
+	attempts INTEGER NOT NULL DEFAULT 0,
+	http_status INTEGER NOT NULL DEFAULT 0,
+	pages INTEGER NOT NULL DEFAULT 0,
+	fetched INTEGER NOT NULL DEFAULT 0,
+	stored INTEGER NOT NULL DEFAULT 0,
+	updated INTEGER NOT NULL DEFAULT 0,
+	error TEXT,
+	-- Storefronts that failed while others were fetched; not a failed run
+	storefront_errors TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_runs_app_started ON poll_runs(app_id, started_at DESC);
+
+CREATE TABLE IF NOT EXISTS circuit_breakers (
+	app_id TEXT PRIMARY KEY,
+	state TEXT NOT NULL DEFAULT 'closed',
+	failures INTEGER NOT NULL DEFAULT 0,
+	opened_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS feed_cache (
+	app_id TEXT NOT NULL,
+	country TEXT NOT NULL,
+	etag TEXT NOT NULL DEFAULT '',
+	last_modified TEXT NOT NULL DEFAULT '',
+	updated_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (app_id, country)
+);
+
+CREATE TABLE IF NOT EXISTS quarantined_entries (
+	id BIGSERIAL PRIMARY KEY,
+	app_id TEXT NOT NULL,
+	country TEXT NOT NULL,
+	entry_id TEXT NOT NULL DEFAULT '',
+	reason TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	checksum TEXT NOT NULL,
+	quarantined_at TIMESTAMPTZ NOT NULL,
+	resolved_at TIMESTAMPTZ,
+	UNIQUE (app_id, checksum)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quarantined_entries_app ON quarantined_entries(app_id, quarantined_at DESC);
+
+CREATE TABLE IF NOT EXISTS review_responses (
+	review_id TEXT PRIMARY KEY,
+	app_id TEXT NOT NULL,
+	body TEXT NOT NULL,
+	source TEXT NOT NULL,
+	responded_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_responses_app ON review_responses(app_id);
+
+CREATE TABLE IF NOT EXISTS apps (
+	app_id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	artist TEXT NOT NULL DEFAULT '',
+	bundle_id TEXT NOT NULL DEFAULT '',
+	icon_url TEXT NOT NULL DEFAULT '',
+	category TEXT NOT NULL DEFAULT '',
+	store_url TEXT NOT NULL DEFAULT '',
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- A default app config for the default app ID used in the frontend, polled
+-- every 5 minutes (in nanoseconds)
+INSERT INTO app_configs (app_id, poll_interval, is_active)
+SELECT '595068606', 300000000000, TRUE
+WHERE NOT EXISTS (SELECT 1 FROM app_configs);
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
+)
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	backends := map[string]func(t *testing.T) config.DatabaseConfig{
+		config.DriverSQLite: func(t *testing.T) config.DatabaseConfig {
+			return config.DatabaseConfig{Driver: config.DriverSQLite, Path: filepath.Join(t.TempDir(), "reviews.db")}
+		},
+		config.DriverPostgres: func(t *testing.T) config.DatabaseConfig {
+			return config.DatabaseConfig{Driver: config.DriverPostgres, URL: postgresTestURL(t)}
+		},
+	}
+
+	for driver, database := range backends {
+		t.Run(driver, func(t *testing.T) {
+			migrator, err := NewMigrator(database(t))
+			if err != nil {
+				t.Fatalf("Failed to create migrator: %v", err)
+			}
+			defer migrator.Close()
+
+			statuses, err := migrator.Status()
+			if err != nil {
+				t.Fatalf("Failed to get status: %v", err)
+			}
+			if len(statuses) == 0 || statuses[0].AppliedAt != nil {
+				t.Fatalf("Expected pending migrations, got %+v", statuses)
+			}
+
+			applied, err := migrator.Up()
+			if err != nil {
+				t.Fatalf("Failed to migrate up: %v", err)
+			}
+			if len(applied) != len(statuses) {
+				t.Errorf("Expected %d migrations applied, got %d", len(statuses), len(applied))
+			}
+
+			var configs int
+			if err := migrator.db.Get(&configs, "SELECT COUNT(*) FROM app_configs"); err != nil {
+				t.Fatalf("Expected app_configs to exist: %v", err)
+			}
+			if configs != 1 {
+				t.Errorf("Expected the default app config, got %d configs", configs)
+			}
+
+			// Running again is a no-op
+			applied, err = migrator.Up()
+			if err != nil || len(applied) != 0 {
+				t.Errorf("Expected nothing left to apply, got %d (%v)", len(applied), err)
+			}
+
+			latest := statuses[len(statuses)-1]
+			rolledBack, err := migrator.Down()
+			if err != nil {
+				t.Fatalf("Failed to migrate down: %v", err)
+			}
+			if rolledBack == nil || rolledBack.Version != latest.Version {
+				t.Fatalf("Expected migration %d rolled back, got %+v", latest.Version, rolledBack)
+			}
+
+			statuses, err = migrator.Status()
+			if err != nil {
+				t.Fatalf("Failed to get status: %v", err)
+			}
+			if statuses[len(statuses)-1].AppliedAt != nil {
+				t.Errorf("Expected migration %d to be pending again", latest.Version)
+			}
+		})
+	}
+}
+
+func TestMigrator_AdoptsLegacySQLiteDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reviews.db")
+
+	// The shape of the original schema, before countries, versions and votes
+	db, err := sqlx.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE reviews (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL,
+			author TEXT NOT NULL,
+			rating INTEGER NOT NULL,
+			title TEXT,
+			content TEXT NOT NULL,
+			submitted_date DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE app_configs (
+			app_id TEXT PRIMARY KEY,
+			poll_interval INTEGER DEFAULT 300000000000,
+			last_poll DATETIME,
+			is_active BOOLEAN DEFAULT TRUE
+		);
+		INSERT INTO app_configs (app_id) VALUES ('123456');
+		INSERT INTO reviews (id, app_id, author, rating, content, submitted_date) VALUES ('old-review', '123456', 'Test User', 4, 'Still here', '2024-01-01 00:00:00');
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	repo, err := NewSQLiteRepository(path)
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	defer repo.Close()
+
+	review, err := repo.GetReview("old-review")
+	if err != nil || review == nil {
+		t.Fatalf("Expected the existing review to survive, got %v", err)
+	}
+	if review.Country != "us" || review.Platform != "ios" {
+		t.Errorf("Expected backfilled defaults, got country %q platform %q", review.Country, review.Platform)
+	}
+
+	appConfig, err := repo.GetAppConfig("123456")
+	if err != nil || appConfig == nil {
+		t.Fatalf("Expected the existing app config, got %v", err)
+	}
+	if active, err := repo.GetActiveApps(); err != nil || len(active) != 1 {
+		t.Errorf("Expected no default app config added alongside existing ones, got %v (%v)", active, err)
+	}
+}
@@ -0,0 +1,656 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+)
+
+// PostgresRepository stores reviews in Postgres, so several replicas can
+// share one database. It behaves exactly like SQLiteRepository.
+type PostgresRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresRepository(databaseURL string) (*PostgresRepository, error) {
+	db, err := sqlx.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	repo := &PostgresRepository{db: db}
+	if err := repo.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return repo, nil
+}
+
+func (r *PostgresRepository) migrate() error {
+	migrator, err := newMigrator(r.db, config.DriverPostgres)
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up()
+	return err
+}
+
+func (r *PostgresRepository) CreateReview(review *models.Review) error {
+	setReviewDefaults(review)
+
+	query := `
+		INSERT INTO reviews
+		(id, app_id, platform, country, author, rating, title, content, app_version, vote_sum, vote_count, author_uri, review_url, submitted_date, created_at, developer_response, developer_response_date)
+		VALUES (:id, :app_id, :platform, :country, :author, :rating, :title, :content, :app_version, :vote_sum, :vote_count, :author_uri, :review_url, :submitted_date, :created_at, :developer_response, :developer_response_date)
+		ON CONFLICT DO NOTHING
+	`
+	_, err := r.db.NamedExec(query, review)
+	return err
+}
+
+func (r *PostgresRepository) CreateReviews(ctx context.Context, reviews []models.Review) (int, error) {
+	tx, err := r.BeginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	inserted, err := tx.CreateReviews(ctx, reviews)
+	if err != nil {
+		return 0, err
+	}
+	return inserted, tx.Commit()
+}
+
+func (r *PostgresRepository) SaveReview(review *models.Review) (ReviewChange, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return ReviewUnchanged, err
+	}
+	defer tx.Rollback()
+
+	change, err := savePostgresReview(tx, review)
+	if err != nil {
+		return ReviewUnchanged, err
+	}
+	return change, tx.Commit()
+}
+
+func savePostgresReview(tx *sqlx.Tx, review *models.Review) (ReviewChange, error) {
+	setReviewDefaults(review)
+
+	// Locking the row keeps two replicas from recording the same edit twice
+	var current models.Review
+	err := tx.Get(&current, "SELECT * FROM reviews WHERE id = $1 FOR UPDATE", review.ID)
+	if err == sql.ErrNoRows {
+		result, err := tx.NamedExec(`
+			INSERT INTO reviews
+			(id, app_id, platform, country, author, rating, title, content, app_version, vote_sum, vote_count, author_uri, review_url, submitted_date, created_at, developer_response, developer_response_date)
+			VALUES (:id, :app_id, :platform, :country, :author, :rating, :title, :content, :app_version, :vote_sum, :vote_count, :author_uri, :review_url, :submitted_date, :created_at, :developer_response, :developer_response_date)
+			ON CONFLICT DO NOTHING
+		`, review)
+		if err != nil {
+			return ReviewUnchanged, err
+		}
+		// Another replica may have inserted it since the SELECT
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return ReviewUnchanged, err
+		}
+		return ReviewInserted, nil
+	}
+	if err != nil {
+		return ReviewUnchanged, err
+	}
+
+	change := ReviewUnchanged
+	if reviewEdited(&current, review) {
+		_, err = tx.Exec(`
+			INSERT INTO review_revisions
+			(review_id, rating, title, content, app_version, submitted_date, revised_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, current.ID, current.Rating, current.Title, current.Content, current.AppVersion, current.SubmittedDate, time.Now())
+		if err != nil {
+			return ReviewUnchanged, err
+		}
+
+		_, err = tx.NamedExec(`
+			UPDATE reviews
+			SET rating = :rating, title = :title, content = :content, app_version = :app_version, submitted_date = :submitted_date
+			WHERE id = :id
+		`, review)
+		if err != nil {
+			return ReviewUnchanged, err
+		}
+		change = ReviewUpdated
+	}
+
+	// Helpfulness votes move constantly and are not worth a revision.
+	_, err = tx.Exec("UPDATE reviews SET vote_sum = $1, vote_count = $2 WHERE id = $3", review.VoteSum, review.VoteCount, review.ID)
+	if err != nil {
+		return ReviewUnchanged, err
+	}
+
+	// Nor is our own reply. Sources that don't report replies leave it alone.
+	if review.DeveloperResponse != nil {
+		_, err = tx.Exec("UPDATE reviews SET developer_response = $1, developer_response_date = $2 WHERE id = $3", review.DeveloperResponse, review.DeveloperResponseDate, review.ID)
+		if err != nil {
+			return ReviewUnchanged, err
+		}
+	}
+
+	return change, nil
+}
+
+func (r *PostgresRepository) BackfillReview(review *models.Review) (ReviewChange, error) {
+	setReviewDefaults(review)
+
+	result, err := r.db.NamedExec(`
+		INSERT INTO reviews
+		(id, app_id, platform, country, author, rating, title, content, app_version, vote_sum, vote_count, author_uri, review_url, submitted_date, created_at, developer_response, developer_response_date)
+		VALUES (:id, :app_id, :platform, :country, :author, :rating, :title, :content, :app_version, :vote_sum, :vote_count, :author_uri, :review_url, :submitted_date, :created_at, :developer_response, :developer_response_date)
+		ON CONFLICT DO NOTHING
+	`, review)
+	if err != nil {
+		return ReviewUnchanged, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return ReviewUnchanged, err
+	} else if n > 0 {
+		return ReviewInserted, nil
+	}
+
+	// Only rows with something to fill are touched, so RowsAffected tells
+	// us whether anything changed. Postgres can't infer a type for a
+	// parameter that is only checked for NULL, hence the casts.
+	result, err = r.db.NamedExec(`
+		UPDATE reviews SET
+			app_version = CASE WHEN app_version = '' THEN :app_version ELSE app_version END,
+			author_uri = CASE WHEN author_uri = '' THEN :author_uri ELSE author_uri END,
+			review_url = CASE WHEN review_url = '' THEN :review_url ELSE review_url END,
+			vote_sum = CASE WHEN vote_sum = 0 AND vote_count = 0 THEN :vote_sum ELSE vote_sum END,
+			vote_count = CASE WHEN vote_sum = 0 AND vote_count = 0 THEN :vote_count ELSE vote_count END,
+			developer_response_date = CASE WHEN developer_response IS NULL THEN CAST(:developer_response_date AS TIMESTAMPTZ) ELSE developer_response_date END,
+			developer_response = COALESCE(developer_response, CAST(:developer_response AS TEXT))
+		WHERE id = :id AND (
+			(app_version = '' AND :app_version != '') OR
+			(author_uri = '' AND :author_uri != '') OR
+			(review_url = '' AND :review_url != '') OR
+			(vote_sum = 0 AND vote_count = 0 AND (:vote_sum != 0 OR :vote_count != 0)) OR
+			(developer_response IS NULL AND CAST(:developer_response AS TEXT) IS NOT NULL)
+		)
+	`, review)
+	if err != nil {
+		return ReviewUnchanged, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return ReviewUnchanged, err
+	}
+	return ReviewUpdated, nil
+}
+
+func (r *PostgresRepository) GetReview(id string) (*models.Review, error) {
+	var review models.Review
+	err := r.db.Get(&review, "SELECT "+reviewColumns+" FROM reviews WHERE id = $1", id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (r *PostgresRepository) GetReviewRevisions(reviewID string) ([]models.ReviewRevision, error) {
+	var revisions []models.ReviewRevision
+	err := r.db.Select(&revisions, "SELECT * FROM review_revisions WHERE review_id = $1 ORDER BY revised_at DESC, id DESC", reviewID)
+	return revisions, err
+}
+
+func (r *PostgresRepository) GetReviews(appID string, hours int, limit int) ([]models.Review, error) {
+	return r.QueryReviews(ReviewFilter{AppID: appID, Hours: hours, Limit: limit})
+}
+
+func (r *PostgresRepository) QueryReviews(filter ReviewFilter) ([]models.Review, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.AppID != "" {
+		conditions = append(conditions, "app_id = ?")
+		args = append(args, filter.AppID)
+	}
+	if filter.Country != "" {
+		conditions = append(conditions, "country = ?")
+		args = append(args, filter.Country)
+	}
+	if filter.AppVersion != "" {
+		conditions = append(conditions, "app_version = ?")
+		args = append(args, filter.AppVersion)
+	}
+	if filter.Responded != nil {
+		responded := "id IN (SELECT review_id FROM review_responses)"
+		if !*filter.Responded {
+			responded = "id NOT IN (SELECT review_id FROM review_responses)"
+		}
+		conditions = append(conditions, responded)
+	}
+	if filter.Hours > 0 {
+		conditions = append(conditions, "submitted_date >= NOW() - make_interval(hours => ?)")
+		args = append(args, filter.Hours)
+	}
+
+	query := "SELECT " + reviewColumns + " FROM reviews"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	switch filter.SortBy {
+	case SortMostHelpful:
+		query += " ORDER BY vote_sum DESC, vote_count DESC, submitted_date DESC"
+	default:
+		query += " ORDER BY submitted_date DESC"
+	}
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	var reviews []models.Review
+	err := r.db.Select(&reviews, r.db.Rebind(query), args...)
+	return reviews, err
+}
+
+func (r *PostgresRepository) ReviewExists(id string) (bool, error) {
+	var count int
+	err := r.db.Get(&count, "SELECT COUNT(*) FROM reviews WHERE id = $1", id)
+	return count > 0, err
+}
+
+func (r *PostgresRepository) StoredReviewIDs(ids []string) (map[string]bool, error) {
+	return storedReviewIDs(r.db, ids)
+}
+
+func (r *PostgresRepository) GetVersionRatings(appID string) ([]models.VersionRating, error) {
+	query := `
+		SELECT app_version, COUNT(*) AS count, AVG(rating) AS average_rating, SUM(vote_sum) AS vote_sum
+		FROM reviews
+		WHERE app_id = $1
+		GROUP BY app_version
+		ORDER BY MAX(submitted_date) DESC
+	`
+
+	var ratings []models.VersionRating
+	err := r.db.Select(&ratings, query, appID)
+	return ratings, err
+}
+
+func (r *PostgresRepository) CountReviewsByApp() (map[string]int, error) {
+	var rows []struct {
+		AppID string `db:"app_id"`
+		Count int    `db:"count"`
+	}
+	if err := r.db.Select(&rows, "SELECT app_id, COUNT(*) AS count FROM reviews GROUP BY app_id"); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.AppID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *PostgresRepository) SaveResponse(response *models.ReviewResponse) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := savePostgresResponse(tx, response); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func savePostgresResponse(tx *sqlx.Tx, response *models.ReviewResponse) error {
+	if response.UpdatedAt.IsZero() {
+		response.UpdatedAt = time.Now()
+	}
+
+	// Kept in Go like SQLiteRepository, so the caller sees the time stored
+	var respondedAt time.Time
+	err := tx.Get(&respondedAt, "SELECT responded_at FROM review_responses WHERE review_id = $1 FOR UPDATE", response.ReviewID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && respondedAt.Before(response.RespondedAt) {
+		response.RespondedAt = respondedAt
+	}
+
+	_, err = tx.NamedExec(`
+		INSERT INTO review_responses
+		(review_id, app_id, body, source, responded_at, updated_at)
+		VALUES (:review_id, :app_id, :body, :source, :responded_at, :updated_at)
+		ON CONFLICT (review_id) DO UPDATE SET
+			app_id = EXCLUDED.app_id, body = EXCLUDED.body, source = EXCLUDED.source,
+			responded_at = EXCLUDED.responded_at, updated_at = EXCLUDED.updated_at
+	`, response)
+	if err != nil {
+		return err
+	}
+
+	// Mirrored onto the review, which is what listings and exports show
+	_, err = tx.NamedExec(`
+		UPDATE reviews SET developer_response = :body, developer_response_date = :updated_at WHERE id = :review_id
+	`, response)
+	return err
+}
+
+func (r *PostgresRepository) GetResponse(reviewID string) (*models.ReviewResponse, error) {
+	var response models.ReviewResponse
+	err := r.db.Get(&response, "SELECT * FROM review_responses WHERE review_id = $1", reviewID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (r *PostgresRepository) GetResponseTimes(appID string, days int) ([]models.ReviewResponseTime, error) {
+	query := `
+		SELECT reviews.id AS review_id, reviews.rating, reviews.submitted_date, review_responses.responded_at
+		FROM reviews
+		LEFT JOIN review_responses ON review_responses.review_id = reviews.id
+		WHERE reviews.app_id = $1 AND reviews.submitted_date >= NOW() - make_interval(days => $2)
+		ORDER BY reviews.submitted_date DESC
+	`
+
+	var times []models.ReviewResponseTime
+	err := r.db.Select(&times, query, appID, days)
+	return times, err
+}
+
+func (r *PostgresRepository) GetAppConfig(appID string) (*models.AppConfig, error) {
+	var config struct {
+		AppID        string     `db:"app_id"`
+		PollInterval int64      `db:"poll_interval"`
+		LastPoll     *time.Time `db:"last_poll"`
+		IsActive     bool       `db:"is_active"`
+		Countries    string     `db:"countries"`
+		Adaptive     bool       `db:"adaptive"`
+		FeedFormat   string     `db:"feed_format"`
+		Platform     string     `db:"platform"`
+		Source       string     `db:"source"`
+	}
+
+	err := r.db.Get(&config, "SELECT app_id, poll_interval, last_poll, is_active, countries, adaptive, feed_format, platform, source FROM app_configs WHERE app_id = $1", appID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AppConfig{
+		AppID:        config.AppID,
+		PollInterval: time.Duration(config.PollInterval),
+		LastPoll:     config.LastPoll,
+		IsActive:     config.IsActive,
+		Countries:    splitCountries(config.Countries),
+		Adaptive:     config.Adaptive,
+		FeedFormat:   config.FeedFormat,
+		Platform:     config.Platform,
+		Source:       config.Source,
+	}, nil
+}
+
+func (r *PostgresRepository) UpsertAppConfig(config *models.AppConfig) error {
+	feedFormat := config.FeedFormat
+	if feedFormat == "" {
+		feedFormat = models.FeedFormatJSON
+	}
+	platform := config.Platform
+	if platform == "" {
+		platform = models.PlatformIOS
+	}
+
+	query := `
+		INSERT INTO app_configs
+		(app_id, poll_interval, last_poll, is_active, countries, adaptive, feed_format, platform, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (app_id) DO UPDATE SET
+			poll_interval = EXCLUDED.poll_interval, last_poll = EXCLUDED.last_poll, is_active = EXCLUDED.is_active,
+			countries = EXCLUDED.countries, adaptive = EXCLUDED.adaptive, feed_format = EXCLUDED.feed_format,
+			platform = EXCLUDED.platform, source = EXCLUDED.source
+	`
+	_, err := r.db.Exec(query, config.AppID, int64(config.PollInterval), config.LastPoll, config.IsActive, joinCountries(config.Countries), config.Adaptive, feedFormat, platform, config.Source)
+	return err
+}
+
+func (r *PostgresRepository) UpdateLastPoll(appID string, polledAt time.Time) error {
+	_, err := r.db.Exec("UPDATE app_configs SET last_poll = $1 WHERE app_id = $2", polledAt, appID)
+	return err
+}
+
+func (r *PostgresRepository) GetActiveApps() ([]string, error) {
+	var appIDs []string
+	err := r.db.Select(&appIDs, "SELECT app_id FROM app_configs WHERE is_active = TRUE")
+	return appIDs, err
+}
+
+func (r *PostgresRepository) UpsertAppMetadata(metadata *models.AppMetadata) error {
+	query := `
+		INSERT INTO apps
+		(app_id, name, artist, bundle_id, icon_url, category, store_url, updated_at)
+		VALUES (:app_id, :name, :artist, :bundle_id, :icon_url, :category, :store_url, :updated_at)
+		ON CONFLICT (app_id) DO UPDATE SET
+			name = EXCLUDED.name, artist = EXCLUDED.artist, bundle_id = EXCLUDED.bundle_id, icon_url = EXCLUDED.icon_url,
+			category = EXCLUDED.category, store_url = EXCLUDED.store_url, updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.NamedExec(query, metadata)
+	return err
+}
+
+func (r *PostgresRepository) GetAppMetadata(appID string) (*models.AppMetadata, error) {
+	var metadata models.AppMetadata
+	err := r.db.Get(&metadata, "SELECT * FROM apps WHERE app_id = $1", appID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+func (r *PostgresRepository) CreatePollRun(run *models.PollRun) error {
+	// lib/pq doesn't support LastInsertId, so the ID comes back via RETURNING
+	stmt, err := r.db.PrepareNamed(`
+		INSERT INTO poll_runs
+		(app_id, started_at, duration_ms, attempts, http_status, pages, fetched, stored, updated, error, storefront_errors)
+		VALUES (:app_id, :started_at, :duration_ms, :attempts, :http_status, :pages, :fetched, :stored, :updated, :error, :storefront_errors)
+		RETURNING id
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	return stmt.Get(&run.ID, run)
+}
+
+func (r *PostgresRepository) GetPollRuns(appID string, limit int) ([]models.PollRun, error) {
+	var runs []models.PollRun
+	err := r.db.Select(&runs, "SELECT * FROM poll_runs WHERE app_id = $1 ORDER BY started_at DESC, id DESC LIMIT $2", appID, limit)
+	return runs, err
+}
+
+func (r *PostgresRepository) GetCircuitBreaker(appID string) (*models.CircuitBreaker, error) {
+	var breaker models.CircuitBreaker
+	err := r.db.Get(&breaker, "SELECT * FROM circuit_breakers WHERE app_id = $1", appID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &breaker, nil
+}
+
+func (r *PostgresRepository) UpsertCircuitBreaker(breaker *models.CircuitBreaker) error {
+	query := `
+		INSERT INTO circuit_breakers
+		(app_id, state, failures, opened_at, updated_at)
+		VALUES (:app_id, :state, :failures, :opened_at, :updated_at)
+		ON CONFLICT (app_id) DO UPDATE SET
+			state = EXCLUDED.state, failures = EXCLUDED.failures, opened_at = EXCLUDED.opened_at, updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.NamedExec(query, breaker)
+	return err
+}
+
+func (r *PostgresRepository) GetFeedCache(appID string) ([]models.FeedCache, error) {
+	var entries []models.FeedCache
+	err := r.db.Select(&entries, "SELECT * FROM feed_cache WHERE app_id = $1", appID)
+	return entries, err
+}
+
+func (r *PostgresRepository) UpsertFeedCache(entry *models.FeedCache) error {
+	query := `
+		INSERT INTO feed_cache
+		(app_id, country, etag, last_modified, updated_at)
+		VALUES (:app_id, :country, :etag, :last_modified, :updated_at)
+		ON CONFLICT (app_id, country) DO UPDATE SET
+			etag = EXCLUDED.etag, last_modified = EXCLUDED.last_modified, updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.NamedExec(query, entry)
+	return err
+}
+
+func (r *PostgresRepository) QuarantineEntry(entry *models.QuarantinedEntry) error {
+	query := `
+		INSERT INTO quarantined_entries
+		(app_id, country, entry_id, reason, payload, checksum, quarantined_at)
+		VALUES (:app_id, :country, :entry_id, :reason, :payload, :checksum, :quarantined_at)
+		ON CONFLICT DO NOTHING
+	`
+	_, err := r.db.NamedExec(query, entry)
+	return err
+}
+
+func (r *PostgresRepository) GetQuarantinedEntries(filter QuarantineFilter) ([]models.QuarantinedEntry, error) {
+	query := "SELECT * FROM quarantined_entries WHERE 1 = 1"
+	var args []interface{}
+
+	if filter.AppID != "" {
+		query += " AND app_id = ?"
+		args = append(args, filter.AppID)
+	}
+	if !filter.IncludeResolved {
+		query += " AND resolved_at IS NULL"
+	}
+
+	query += " ORDER BY quarantined_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	var entries []models.QuarantinedEntry
+	err := r.db.Select(&entries, r.db.Rebind(query), args...)
+	return entries, err
+}
+
+func (r *PostgresRepository) ResolveQuarantinedEntry(id int64, resolvedAt time.Time) error {
+	_, err := r.db.Exec("UPDATE quarantined_entries SET resolved_at = $1 WHERE id = $2", resolvedAt, id)
+	return err
+}
+
+func (r *PostgresRepository) BeginTx(ctx context.Context) (Tx, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresTx{tx: tx}, nil
+}
+
+func (r *PostgresRepository) Close() error {
+	return r.db.Close()
+}
+
+type postgresTx struct {
+	tx *sqlx.Tx
+}
+
+func (t *postgresTx) CreateReviews(ctx context.Context, reviews []models.Review) (int, error) {
+	stmt, err := t.tx.PrepareNamedContext(ctx, `
+		INSERT INTO reviews
+		(id, app_id, platform, country, author, rating, title, content, app_version, vote_sum, vote_count, author_uri, review_url, submitted_date, created_at, developer_response, developer_response_date)
+		VALUES (:id, :app_id, :platform, :country, :author, :rating, :title, :content, :app_version, :vote_sum, :vote_count, :author_uri, :review_url, :submitted_date, :created_at, :developer_response, :developer_response_date)
+		ON CONFLICT DO NOTHING
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	for i := range reviews {
+		review := &reviews[i]
+		setReviewDefaults(review)
+
+		result, err := stmt.ExecContext(ctx, review)
+		if err != nil {
+			return 0, fmt.Errorf("review %s: %w", review.ID, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		inserted += int(n)
+	}
+	return inserted, nil
+}
+
+func (t *postgresTx) SaveReview(review *models.Review) (ReviewChange, error) {
+	return savePostgresReview(t.tx, review)
+}
+
+func (t *postgresTx) StoredReviews(ids []string) (map[string]models.Review, error) {
+	return storedReviews(t.tx, ids)
+}
+
+func (t *postgresTx) SaveResponse(response *models.ReviewResponse) error {
+	return savePostgresResponse(t.tx, response)
+}
+
+func (t *postgresTx) ClearResponse(reviewID, source string) (bool, error) {
+	return clearResponse(t.tx, reviewID, source)
+}
+
+func (t *postgresTx) UpdateLastPoll(appID string, polledAt time.Time) error {
+	_, err := t.tx.Exec("UPDATE app_configs SET last_poll = $1 WHERE app_id = $2", polledAt, appID)
+	return err
+}
+
+func (t *postgresTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *postgresTx) Rollback() error {
+	return t.tx.Rollback()
+}
@@ -0,0 +1,495 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+)
+
+// forEachBackend runs test against a fresh SQLite database and, when
+// TEST_DATABASE_URL is set to a postgres:// URL, a fresh Postgres schema.
+func forEachBackend(t *testing.T, test func(t *testing.T, repo Repository)) {
+	t.Run("sqlite", func(t *testing.T) {
+		repo, err := NewSQLiteRepository(":memory:")
+		if err != nil {
+			t.Fatalf("Failed to create test repository: %v", err)
+		}
+		defer repo.Close()
+		test(t, repo)
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		repo := newPostgresTestRepository(t)
+		defer repo.Close()
+		test(t, repo)
+	})
+}
+
+// newPostgresTestRepository migrates a schema of its own, dropped when the
+// test ends, so tests don't see each other's rows.
+func newPostgresTestRepository(t *testing.T) *PostgresRepository {
+	t.Helper()
+
+	repo, err := NewPostgresRepository(postgresTestURL(t))
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	return repo
+}
+
+// postgresTestURL creates an empty schema for the test and returns
+// TEST_DATABASE_URL with that schema on its search path.
+func postgresTestURL(t *testing.T) string {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	admin, err := sqlx.Open("postgres", databaseURL)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		t.Fatalf("Invalid TEST_DATABASE_URL: %v", err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func TestRepository_CreateAndGetReviews(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo Repository) {
+		review := &models.Review{
+			ID:            "test-review-1",
+			AppID:         "123456",
+			Author:        "Test User",
+			Rating:        5,
+			Title:         stringPtr("Great App"),
+			Content:       "This is a test review",
+			SubmittedDate: time.Now(),
+			CreatedAt:     time.Now(),
+		}
+
+		err := repo.CreateReview(review)
+		if err != nil {
+			t.Fatalf("Failed to create review: %v", err)
+		}
+
+		reviews, err := repo.GetReviews("123456", 24, 10)
+		if err != nil {
+			t.Fatalf("Failed to get reviews: %v", err)
+		}
+
+		if len(reviews) != 1 {
+			t.Fatalf("Expected 1 review, got %d", len(reviews))
+		}
+
+		if reviews[0].Author != "Test User" {
+			t.Errorf("Expected author 'Test User', got '%s'", reviews[0].Author)
+		}
+
+		exists, err := repo.ReviewExists("test-review-1")
+		if err != nil {
+			t.Fatalf("Failed to check if review exists: %v", err)
+		}
+
+		if !exists {
+			t.Error("Review should exist")
+		}
+	})
+}
+
+func TestRepository_CreateReviews(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo Repository) {
+		ctx := context.Background()
+		if err := repo.UpsertAppConfig(&models.AppConfig{AppID: "123456", PollInterval: time.Hour, IsActive: true}); err != nil {
+			t.Fatalf("Failed to create app config: %v", err)
+		}
+
+		var reviews []models.Review
+		for i := 0; i < 3; i++ {
+			reviews = append(reviews, models.Review{
+				ID:            fmt.Sprintf("review-%d", i),
+				AppID:         "123456",
+				Author:        "Test User",
+				Rating:        5,
+				Content:       "Review",
+				SubmittedDate: time.Now(),
+				CreatedAt:     time.Now(),
+			})
+		}
+		if err := repo.CreateReview(&reviews[0]); err != nil {
+			t.Fatalf("Failed to create review: %v", err)
+		}
+
+		inserted, err := repo.CreateReviews(ctx, reviews)
+		if err != nil {
+			t.Fatalf("Failed to create reviews: %v", err)
+		}
+		if inserted != 2 {
+			t.Errorf("Expected 2 new reviews, got %d", inserted)
+		}
+		if known, err := repo.StoredReviewIDs([]string{"review-2", "review-9"}); err != nil || !known["review-2"] || known["review-9"] {
+			t.Errorf("Expected only review-2 to be known, got %v (%v)", known, err)
+		}
+
+		// Nothing written in a rolled back transaction is kept
+		tx, err := repo.BeginTx(ctx)
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		stored, err := tx.StoredReviews([]string{"review-1", "review-3"})
+		if err != nil {
+			t.Fatalf("Failed to look up reviews: %v", err)
+		}
+		if _, ok := stored["review-3"]; ok || len(stored) != 1 || stored["review-1"].Content != reviews[0].Content {
+			t.Errorf("Expected only review-1 to be stored, got %+v", stored)
+		}
+		reviews[0].ID = "review-3"
+		if inserted, err := tx.CreateReviews(ctx, reviews[:1]); err != nil || inserted != 1 {
+			t.Fatalf("Expected 1 new review, got %d (%v)", inserted, err)
+		}
+		if err := tx.UpdateLastPoll("123456", time.Now()); err != nil {
+			t.Fatalf("Failed to update last poll: %v", err)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Failed to roll back: %v", err)
+		}
+
+		if exists, err := repo.ReviewExists("review-3"); err != nil || exists {
+			t.Errorf("Expected review-3 to be rolled back, got %v (%v)", exists, err)
+		}
+		config, err := repo.GetAppConfig("123456")
+		if err != nil {
+			t.Fatalf("Failed to get app config: %v", err)
+		}
+		if config.LastPoll != nil {
+			t.Errorf("Expected last poll to be rolled back, got %v", config.LastPoll)
+		}
+	})
+}
+
+func TestRepository_QueryReviewsByCountry(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo Repository) {
+		for i, country := range []string{"us", "gb", "gb"} {
+			review := &models.Review{
+				ID:            fmt.Sprintf("review-%d", i),
+				AppID:         "123456",
+				Country:       country,
+				Author:        "Test User",
+				Rating:        4,
+				Content:       "Review from " + country,
+				SubmittedDate: time.Now(),
+				CreatedAt:     time.Now(),
+			}
+			if err := repo.CreateReview(review); err != nil {
+				t.Fatalf("Failed to create review: %v", err)
+			}
+		}
+
+		reviews, err := repo.QueryReviews(ReviewFilter{AppID: "123456", Country: "gb", Hours: 24})
+		if err != nil {
+			t.Fatalf("Failed to query reviews: %v", err)
+		}
+
+		if len(reviews) != 2 {
+			t.Fatalf("Expected 2 gb reviews, got %d", len(reviews))
+		}
+
+		for _, review := range reviews {
+			if review.Country != "gb" {
+				t.Errorf("Expected country 'gb', got '%s'", review.Country)
+			}
+		}
+	})
+}
+
+func TestRepository_VersionsAndHelpfulSort(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo Repository) {
+		fixtures := []struct {
+			version string
+			rating  int
+			votes   int
+		}{
+			{"1.0", 2, 10},
+			{"1.0", 4, 1},
+			{"1.1", 5, 3},
+		}
+		for i, f := range fixtures {
+			review := &models.Review{
+				ID:            fmt.Sprintf("review-%d", i),
+				AppID:         "123456",
+				Author:        "Test User",
+				Rating:        f.rating,
+				Content:       "Review",
+				AppVersion:    f.version,
+				VoteSum:       f.votes,
+				VoteCount:     f.votes,
+				SubmittedDate: time.Now().Add(time.Duration(i) * time.Minute),
+				CreatedAt:     time.Now(),
+			}
+			if err := repo.CreateReview(review); err != nil {
+				t.Fatalf("Failed to create review: %v", err)
+			}
+		}
+
+		ratings, err := repo.GetVersionRatings("123456")
+		if err != nil {
+			t.Fatalf("Failed to get version ratings: %v", err)
+		}
+
+		if len(ratings) != 2 {
+			t.Fatalf("Expected 2 versions, got %d", len(ratings))
+		}
+		if ratings[1].AppVersion != "1.0" || ratings[1].Count != 2 || ratings[1].AverageRating != 3 {
+			t.Errorf("Unexpected rating summary for 1.0: %+v", ratings[1])
+		}
+
+		reviews, err := repo.QueryReviews(ReviewFilter{AppID: "123456", SortBy: SortMostHelpful})
+		if err != nil {
+			t.Fatalf("Failed to query reviews: %v", err)
+		}
+
+		if reviews[0].ID != "review-0" {
+			t.Errorf("Expected most helpful review first, got %s", reviews[0].ID)
+		}
+	})
+}
+
+func TestRepository_SaveReviewTracksEdits(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo Repository) {
+		review := &models.Review{
+			ID:            "edited-review",
+			AppID:         "123456",
+			Author:        "Test User",
+			Rating:        1,
+			Title:         stringPtr("Broken"),
+			Content:       "Crashes on launch",
+			SubmittedDate: time.Now().Add(-time.Hour),
+			CreatedAt:     time.Now(),
+		}
+
+		steps := []struct {
+			name   string
+			mutate func(r *models.Review)
+			want   ReviewChange
+		}{
+			{"new review", func(r *models.Review) {}, ReviewInserted},
+			{"votes only", func(r *models.Review) { r.VoteSum, r.VoteCount = 3, 4 }, ReviewUnchanged},
+			{"rating and text edited", func(r *models.Review) {
+				r.Rating = 4
+				r.Content = "Fixed in the latest update"
+				r.SubmittedDate = time.Now()
+			}, ReviewUpdated},
+			{"title removed", func(r *models.Review) { r.Title = nil }, ReviewUpdated},
+			{"response published", func(r *models.Review) {
+				respondedAt := time.Now()
+				r.DeveloperResponse = stringPtr("Thanks, glad it's fixed")
+				r.DeveloperResponseDate = &respondedAt
+			}, ReviewUnchanged},
+			{"fetched without responses", func(r *models.Review) {
+				r.DeveloperResponse = nil
+				r.DeveloperResponseDate = nil
+			}, ReviewUnchanged},
+		}
+
+		for _, step := range steps {
+			step.mutate(review)
+			change, err := repo.SaveReview(review)
+			if err != nil {
+				t.Fatalf("%s: failed to save review: %v", step.name, err)
+			}
+			if change != step.want {
+				t.Errorf("%s: expected change %d, got %d", step.name, step.want, change)
+			}
+		}
+
+		stored, err := repo.GetReview("edited-review")
+		if err != nil {
+			t.Fatalf("Failed to get review: %v", err)
+		}
+		if stored.Rating != 4 || stored.Title != nil || stored.VoteSum != 3 {
+			t.Errorf("Stored review not updated: %+v", stored)
+		}
+		if stored.DeveloperResponse == nil || *stored.DeveloperResponse != "Thanks, glad it's fixed" || stored.DeveloperResponseDate == nil {
+			t.Errorf("Expected the developer response to be kept, got %v", stored.DeveloperResponse)
+		}
+
+		revisions, err := repo.GetReviewRevisions("edited-review")
+		if err != nil {
+			t.Fatalf("Failed to get revisions: %v", err)
+		}
+
+		if len(revisions) != 2 {
+			t.Fatalf("Expected 2 revisions, got %d", len(revisions))
+		}
+		if revisions[1].Rating != 1 || revisions[1].Content != "Crashes on launch" {
+			t.Errorf("Oldest revision should hold the original review, got %+v", revisions[1])
+		}
+		if revisions[0].Title == nil || *revisions[0].Title != "Broken" {
+			t.Errorf("Latest revision should hold the previous title, got %+v", revisions[0])
+		}
+	})
+}
+
+func TestRepository_Quarantine(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo Repository) {
+		entry := &models.QuarantinedEntry{
+			AppID:         "123456",
+			Country:       "us",
+			EntryID:       "bad-1",
+			Reason:        "invalid rating format \"five\"",
+			Payload:       `{"id":{"label":"bad-1"}}`,
+			Checksum:      "abc",
+			QuarantinedAt: time.Now(),
+		}
+
+		// The same entry comes back on every poll until the parser is fixed
+		for i := 0; i < 2; i++ {
+			if err := repo.QuarantineEntry(entry); err != nil {
+				t.Fatalf("Failed to quarantine entry: %v", err)
+			}
+		}
+
+		entries, err := repo.GetQuarantinedEntries(QuarantineFilter{AppID: "123456"})
+		if err != nil {
+			t.Fatalf("Failed to get quarantined entries: %v", err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("Expected 1 quarantined entry, got %d", len(entries))
+		}
+		if entries[0].Payload != entry.Payload || entries[0].ResolvedAt != nil {
+			t.Errorf("Unexpected quarantined entry: %+v", entries[0])
+		}
+
+		if err := repo.ResolveQuarantinedEntry(entries[0].ID, time.Now()); err != nil {
+			t.Fatalf("Failed to resolve entry: %v", err)
+		}
+
+		entries, err = repo.GetQuarantinedEntries(QuarantineFilter{AppID: "123456"})
+		if err != nil {
+			t.Fatalf("Failed to get quarantined entries: %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Expected resolved entry to be hidden, got %d entries", len(entries))
+		}
+
+		entries, err = repo.GetQuarantinedEntries(QuarantineFilter{AppID: "123456", IncludeResolved: true})
+		if err != nil {
+			t.Fatalf("Failed to get quarantined entries: %v", err)
+		}
+		if len(entries) != 1 || entries[0].ResolvedAt == nil {
+			t.Errorf("Expected 1 resolved entry, got %+v", entries)
+		}
+	})
+}
+
+func TestRepository_Responses(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo Repository) {
+		// Postgres keeps timestamps to the microsecond
+		submitted := time.Now().Add(-72 * time.Hour).Truncate(time.Microsecond)
+		for i, rating := range []int{1, 5} {
+			review := &models.Review{
+				ID:            fmt.Sprintf("review-%d", i),
+				AppID:         "123456",
+				Author:        "Test User",
+				Rating:        rating,
+				Content:       "Review",
+				SubmittedDate: submitted,
+				CreatedAt:     time.Now(),
+			}
+			if err := repo.CreateReview(review); err != nil {
+				t.Fatalf("Failed to create review: %v", err)
+			}
+		}
+
+		firstReply := submitted.Add(6 * time.Hour)
+		if err := repo.SaveResponse(&models.ReviewResponse{ReviewID: "review-0", AppID: "123456", Body: "Sorry!", Source: models.ResponseSourceManual, RespondedAt: firstReply}); err != nil {
+			t.Fatalf("Failed to save response: %v", err)
+		}
+		// Syncing the same reply after an edit updates the text but not when
+		// the reviewer was first answered
+		if err := repo.SaveResponse(&models.ReviewResponse{ReviewID: "review-0", AppID: "123456", Body: "Sorry, fixed now", Source: models.SourceAppStoreConnect, RespondedAt: firstReply.Add(24 * time.Hour)}); err != nil {
+			t.Fatalf("Failed to save response: %v", err)
+		}
+
+		response, err := repo.GetResponse("review-0")
+		if err != nil || response == nil {
+			t.Fatalf("Expected a stored response, got %v", err)
+		}
+		if response.Body != "Sorry, fixed now" || !response.RespondedAt.Equal(firstReply) {
+			t.Errorf("Expected the new body with the first reply time, got %+v", response)
+		}
+
+		// The reply shows on the review whichever way it was recorded
+		review, err := repo.GetReview("review-0")
+		if err != nil || review == nil {
+			t.Fatalf("Failed to get review: %v", err)
+		}
+		if review.DeveloperResponse == nil || *review.DeveloperResponse != "Sorry, fixed now" || review.DeveloperResponseDate == nil {
+			t.Errorf("Expected the reply on the review, got %v at %v", review.DeveloperResponse, review.DeveloperResponseDate)
+		}
+
+		responded := true
+		reviews, err := repo.QueryReviews(ReviewFilter{AppID: "123456", Responded: &responded})
+		if err != nil {
+			t.Fatalf("Failed to query reviews: %v", err)
+		}
+		if len(reviews) != 1 || reviews[0].ID != "review-0" || !reviews[0].Responded {
+			t.Errorf("Expected only the answered review, got %+v", reviews)
+		}
+
+		responded = false
+		reviews, err = repo.QueryReviews(ReviewFilter{AppID: "123456", Responded: &responded})
+		if err != nil {
+			t.Fatalf("Failed to query reviews: %v", err)
+		}
+		if len(reviews) != 1 || reviews[0].ID != "review-1" || reviews[0].Responded {
+			t.Errorf("Expected only the unanswered review, got %+v", reviews)
+		}
+
+		times, err := repo.GetResponseTimes("123456", 7)
+		if err != nil {
+			t.Fatalf("Failed to get response times: %v", err)
+		}
+		if len(times) != 2 {
+			t.Fatalf("Expected 2 reviews, got %d", len(times))
+		}
+		for _, rt := range times {
+			switch rt.ReviewID {
+			case "review-0":
+				if rt.RespondedAt == nil || !rt.RespondedAt.Equal(firstReply) {
+					t.Errorf("Expected review-0 answered at %s, got %v", firstReply, rt.RespondedAt)
+				}
+			case "review-1":
+				if rt.RespondedAt != nil {
+					t.Errorf("Expected review-1 unanswered, got %v", rt.RespondedAt)
+				}
+			}
+		}
+	})
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -1,12 +1,15 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
 	"github.com/youthtrouble/symmetrical-giggle/internal/models"
 )
 
@@ -20,6 +23,10 @@ func NewSQLiteRepository(dbPath string) (*SQLiteRepository, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	// SQLite serialises writers anyway, and a single connection keeps
+	// transactions and ":memory:" databases from being split across connections.
+	db.SetMaxOpenConns(1)
+
 	repo := &SQLiteRepository{db: db}
 	if err := repo.migrate(); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
@@ -29,78 +36,325 @@ func NewSQLiteRepository(dbPath string) (*SQLiteRepository, error) {
 }
 
 func (r *SQLiteRepository) migrate() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS reviews (
-		id TEXT PRIMARY KEY,
-		app_id TEXT NOT NULL,
-		author TEXT NOT NULL,
-		rating INTEGER NOT NULL,
-		title TEXT,
-		content TEXT NOT NULL,
-		submitted_date DATETIME NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_reviews_app_date ON reviews(app_id, submitted_date DESC);
-	CREATE INDEX IF NOT EXISTS idx_reviews_rating ON reviews(app_id, rating DESC);
-
-	CREATE TABLE IF NOT EXISTS app_configs (
-		app_id TEXT PRIMARY KEY,
-		poll_interval INTEGER DEFAULT 300000000000, -- nanoseconds (5 minutes = 300000000000 ns)
-		last_poll DATETIME,
-		is_active BOOLEAN DEFAULT TRUE
-	);
-	`
-
-	_, err := r.db.Exec(schema)
+	migrator, err := newMigrator(r.db, config.DriverSQLite)
 	if err != nil {
 		return err
 	}
+	_, err = migrator.Up()
+	return err
+}
 
+// upgradeLegacySQLite backfills columns on databases created before
+// versioned migrations, when the schema was a single CREATE TABLE IF NOT
+// EXISTS script that left existing tables untouched. The first migration
+// then adopts them as they are.
+func upgradeLegacySQLite(db *sqlx.DB) error {
 	var count int
-	err = r.db.Get(&count, "SELECT COUNT(*) FROM app_configs")
-	if err != nil {
+	if err := db.Get(&count, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('reviews', 'app_configs')"); err != nil {
 		return err
 	}
+	if count < 2 {
+		return nil
+	}
 
-	if count == 0 {
-		// Inserts a default app config for the default app ID used in the frontend
-		defaultAppID := "595068606"
-		defaultInterval := int64(5 * time.Minute) // 5 minutes in nanoseconds
-
-		_, err = r.db.Exec(`
-			INSERT INTO app_configs (app_id, poll_interval, is_active) 
-			VALUES (?, ?, TRUE)
-		`, defaultAppID, defaultInterval)
-
-		if err != nil {
-			return fmt.Errorf("failed to insert default app config: %w", err)
+	for _, column := range []struct{ table, name, definition string }{
+		{"reviews", "country", "TEXT NOT NULL DEFAULT 'us'"},
+		{"reviews", "app_version", "TEXT NOT NULL DEFAULT ''"},
+		{"reviews", "vote_sum", "INTEGER NOT NULL DEFAULT 0"},
+		{"reviews", "vote_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"reviews", "author_uri", "TEXT NOT NULL DEFAULT ''"},
+		{"reviews", "review_url", "TEXT NOT NULL DEFAULT ''"},
+		{"reviews", "platform", "TEXT NOT NULL DEFAULT 'ios'"},
+		{"reviews", "developer_response", "TEXT"},
+		{"reviews", "developer_response_date", "DATETIME"},
+		{"app_configs", "countries", "TEXT NOT NULL DEFAULT 'us'"},
+		{"app_configs", "adaptive", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"app_configs", "feed_format", "TEXT NOT NULL DEFAULT 'json'"},
+		{"app_configs", "platform", "TEXT NOT NULL DEFAULT 'ios'"},
+		{"app_configs", "source", "TEXT NOT NULL DEFAULT ''"},
+	} {
+		if err := addColumnIfMissing(db, column.table, column.name, column.definition); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
+func addColumnIfMissing(db *sqlx.DB, table, column, definition string) error {
+	var count int
+	err := db.Get(&count, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
 func (r *SQLiteRepository) CreateReview(review *models.Review) error {
+	setReviewDefaults(review)
+
 	query := `
 		INSERT OR IGNORE INTO reviews 
-		(id, app_id, author, rating, title, content, submitted_date, created_at) 
-		VALUES (:id, :app_id, :author, :rating, :title, :content, :submitted_date, :created_at)
+		(id, app_id, platform, country, author, rating, title, content, app_version, vote_sum, vote_count, author_uri, review_url, submitted_date, created_at, developer_response, developer_response_date) 
+		VALUES (:id, :app_id, :platform, :country, :author, :rating, :title, :content, :app_version, :vote_sum, :vote_count, :author_uri, :review_url, :submitted_date, :created_at, :developer_response, :developer_response_date)
 	`
 	_, err := r.db.NamedExec(query, review)
 	return err
 }
 
+func (r *SQLiteRepository) CreateReviews(ctx context.Context, reviews []models.Review) (int, error) {
+	tx, err := r.BeginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	inserted, err := tx.CreateReviews(ctx, reviews)
+	if err != nil {
+		return 0, err
+	}
+	return inserted, tx.Commit()
+}
+
+func (r *SQLiteRepository) SaveReview(review *models.Review) (ReviewChange, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return ReviewUnchanged, err
+	}
+	defer tx.Rollback()
+
+	change, err := saveSQLiteReview(tx, review)
+	if err != nil {
+		return ReviewUnchanged, err
+	}
+	return change, tx.Commit()
+}
+
+func saveSQLiteReview(tx *sqlx.Tx, review *models.Review) (ReviewChange, error) {
+	setReviewDefaults(review)
+
+	var current models.Review
+	err := tx.Get(&current, "SELECT * FROM reviews WHERE id = ?", review.ID)
+	if err == sql.ErrNoRows {
+		_, err = tx.NamedExec(`
+			INSERT INTO reviews 
+			(id, app_id, platform, country, author, rating, title, content, app_version, vote_sum, vote_count, author_uri, review_url, submitted_date, created_at, developer_response, developer_response_date) 
+			VALUES (:id, :app_id, :platform, :country, :author, :rating, :title, :content, :app_version, :vote_sum, :vote_count, :author_uri, :review_url, :submitted_date, :created_at, :developer_response, :developer_response_date)
+		`, review)
+		if err != nil {
+			return ReviewUnchanged, err
+		}
+		return ReviewInserted, nil
+	}
+	if err != nil {
+		return ReviewUnchanged, err
+	}
+
+	change := ReviewUnchanged
+	if reviewEdited(&current, review) {
+		_, err = tx.Exec(`
+			INSERT INTO review_revisions 
+			(review_id, rating, title, content, app_version, submitted_date, revised_at) 
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, current.ID, current.Rating, current.Title, current.Content, current.AppVersion, current.SubmittedDate, time.Now())
+		if err != nil {
+			return ReviewUnchanged, err
+		}
+
+		_, err = tx.NamedExec(`
+			UPDATE reviews 
+			SET rating = :rating, title = :title, content = :content, app_version = :app_version, submitted_date = :submitted_date 
+			WHERE id = :id
+		`, review)
+		if err != nil {
+			return ReviewUnchanged, err
+		}
+		change = ReviewUpdated
+	}
+
+	// Helpfulness votes move constantly and are not worth a revision.
+	_, err = tx.Exec("UPDATE reviews SET vote_sum = ?, vote_count = ? WHERE id = ?", review.VoteSum, review.VoteCount, review.ID)
+	if err != nil {
+		return ReviewUnchanged, err
+	}
+
+	// Nor is our own reply. Sources that don't report replies leave it alone.
+	if review.DeveloperResponse != nil {
+		_, err = tx.Exec("UPDATE reviews SET developer_response = ?, developer_response_date = ? WHERE id = ?", review.DeveloperResponse, review.DeveloperResponseDate, review.ID)
+		if err != nil {
+			return ReviewUnchanged, err
+		}
+	}
+
+	return change, nil
+}
+
+func (r *SQLiteRepository) BackfillReview(review *models.Review) (ReviewChange, error) {
+	setReviewDefaults(review)
+
+	result, err := r.db.NamedExec(`
+		INSERT OR IGNORE INTO reviews 
+		(id, app_id, platform, country, author, rating, title, content, app_version, vote_sum, vote_count, author_uri, review_url, submitted_date, created_at, developer_response, developer_response_date) 
+		VALUES (:id, :app_id, :platform, :country, :author, :rating, :title, :content, :app_version, :vote_sum, :vote_count, :author_uri, :review_url, :submitted_date, :created_at, :developer_response, :developer_response_date)
+	`, review)
+	if err != nil {
+		return ReviewUnchanged, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return ReviewUnchanged, err
+	} else if n > 0 {
+		return ReviewInserted, nil
+	}
+
+	// Only rows with something to fill are touched, so RowsAffected tells
+	// us whether anything changed
+	result, err = r.db.NamedExec(`
+		UPDATE reviews SET 
+			app_version = CASE WHEN app_version = '' THEN :app_version ELSE app_version END,
+			author_uri = CASE WHEN author_uri = '' THEN :author_uri ELSE author_uri END,
+			review_url = CASE WHEN review_url = '' THEN :review_url ELSE review_url END,
+			vote_sum = CASE WHEN vote_sum = 0 AND vote_count = 0 THEN :vote_sum ELSE vote_sum END,
+			vote_count = CASE WHEN vote_sum = 0 AND vote_count = 0 THEN :vote_count ELSE vote_count END,
+			developer_response_date = CASE WHEN developer_response IS NULL THEN :developer_response_date ELSE developer_response_date END,
+			developer_response = COALESCE(developer_response, :developer_response)
+		WHERE id = :id AND (
+			(app_version = '' AND :app_version != '') OR
+			(author_uri = '' AND :author_uri != '') OR
+			(review_url = '' AND :review_url != '') OR
+			(vote_sum = 0 AND vote_count = 0 AND (:vote_sum != 0 OR :vote_count != 0)) OR
+			(developer_response IS NULL AND :developer_response IS NOT NULL)
+		)
+	`, review)
+	if err != nil {
+		return ReviewUnchanged, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return ReviewUnchanged, err
+	}
+	return ReviewUpdated, nil
+}
+
+// setReviewDefaults fills in the platform and, for App Store reviews, the
+// storefront of reviews that don't name them. Google Play reviews aren't
+// tied to a storefront and keep an empty country.
+func setReviewDefaults(review *models.Review) {
+	if review.Platform == "" {
+		review.Platform = models.PlatformIOS
+	}
+	if review.Country == "" && review.Platform == models.PlatformIOS {
+		review.Country = models.DefaultCountry
+	}
+}
+
+func reviewEdited(current, fetched *models.Review) bool {
+	if current.Rating != fetched.Rating || current.Content != fetched.Content {
+		return true
+	}
+	if (current.Title == nil) != (fetched.Title == nil) {
+		return true
+	}
+	return current.Title != nil && *current.Title != *fetched.Title
+}
+
+// NeedsSave reports whether SaveReview would write anything for fetched,
+// given the stored version, so callers can skip reviews that haven't moved.
+func NeedsSave(stored, fetched *models.Review) bool {
+	if reviewEdited(stored, fetched) {
+		return true
+	}
+	if stored.VoteSum != fetched.VoteSum || stored.VoteCount != fetched.VoteCount {
+		return true
+	}
+	if fetched.DeveloperResponse == nil {
+		return false
+	}
+	if stored.DeveloperResponse == nil || *stored.DeveloperResponse != *fetched.DeveloperResponse {
+		return true
+	}
+	if (stored.DeveloperResponseDate == nil) != (fetched.DeveloperResponseDate == nil) {
+		return true
+	}
+	return stored.DeveloperResponseDate != nil && !stored.DeveloperResponseDate.Equal(*fetched.DeveloperResponseDate)
+}
+
+// reviewColumns selects a review along with whether it has been answered.
+const reviewColumns = "reviews.*, EXISTS (SELECT 1 FROM review_responses WHERE review_responses.review_id = reviews.id) AS responded"
+
+func (r *SQLiteRepository) GetReview(id string) (*models.Review, error) {
+	var review models.Review
+	err := r.db.Get(&review, "SELECT "+reviewColumns+" FROM reviews WHERE id = ?", id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (r *SQLiteRepository) GetReviewRevisions(reviewID string) ([]models.ReviewRevision, error) {
+	var revisions []models.ReviewRevision
+	err := r.db.Select(&revisions, "SELECT * FROM review_revisions WHERE review_id = ? ORDER BY revised_at DESC, id DESC", reviewID)
+	return revisions, err
+}
+
 func (r *SQLiteRepository) GetReviews(appID string, hours int, limit int) ([]models.Review, error) {
-	query := `
-		SELECT * FROM reviews 
-		WHERE app_id = ? AND submitted_date >= datetime('now', '-' || ? || ' hours')
-		ORDER BY submitted_date DESC 
-		LIMIT ?
-	`
+	return r.QueryReviews(ReviewFilter{AppID: appID, Hours: hours, Limit: limit})
+}
+
+func (r *SQLiteRepository) QueryReviews(filter ReviewFilter) ([]models.Review, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.AppID != "" {
+		conditions = append(conditions, "app_id = ?")
+		args = append(args, filter.AppID)
+	}
+	if filter.Country != "" {
+		conditions = append(conditions, "country = ?")
+		args = append(args, filter.Country)
+	}
+	if filter.AppVersion != "" {
+		conditions = append(conditions, "app_version = ?")
+		args = append(args, filter.AppVersion)
+	}
+	if filter.Responded != nil {
+		responded := "id IN (SELECT review_id FROM review_responses)"
+		if !*filter.Responded {
+			responded = "id NOT IN (SELECT review_id FROM review_responses)"
+		}
+		conditions = append(conditions, responded)
+	}
+	if filter.Hours > 0 {
+		conditions = append(conditions, "submitted_date >= datetime('now', '-' || ? || ' hours')")
+		args = append(args, filter.Hours)
+	}
+
+	query := "SELECT " + reviewColumns + " FROM reviews"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	switch filter.SortBy {
+	case SortMostHelpful:
+		query += " ORDER BY vote_sum DESC, vote_count DESC, submitted_date DESC"
+	default:
+		query += " ORDER BY submitted_date DESC"
+	}
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
 
 	var reviews []models.Review
-	err := r.db.Select(&reviews, query, appID, hours, limit)
+	err := r.db.Select(&reviews, query, args...)
 	return reviews, err
 }
 
@@ -110,15 +364,125 @@ func (r *SQLiteRepository) ReviewExists(id string) (bool, error) {
 	return count > 0, err
 }
 
+func (r *SQLiteRepository) StoredReviewIDs(ids []string) (map[string]bool, error) {
+	return storedReviewIDs(r.db, ids)
+}
+
+func (r *SQLiteRepository) GetVersionRatings(appID string) ([]models.VersionRating, error) {
+	query := `
+		SELECT app_version, COUNT(*) AS count, AVG(rating) AS average_rating, SUM(vote_sum) AS vote_sum
+		FROM reviews
+		WHERE app_id = ?
+		GROUP BY app_version
+		ORDER BY MAX(submitted_date) DESC
+	`
+
+	var ratings []models.VersionRating
+	err := r.db.Select(&ratings, query, appID)
+	return ratings, err
+}
+
+func (r *SQLiteRepository) CountReviewsByApp() (map[string]int, error) {
+	var rows []struct {
+		AppID string `db:"app_id"`
+		Count int    `db:"count"`
+	}
+	if err := r.db.Select(&rows, "SELECT app_id, COUNT(*) AS count FROM reviews GROUP BY app_id"); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.AppID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *SQLiteRepository) SaveResponse(response *models.ReviewResponse) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveSQLiteResponse(tx, response); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func saveSQLiteResponse(tx *sqlx.Tx, response *models.ReviewResponse) error {
+	if response.UpdatedAt.IsZero() {
+		response.UpdatedAt = time.Now()
+	}
+
+	// Compared here rather than in SQL, as stored timestamps keep their
+	// original offset and don't sort as text
+	var respondedAt time.Time
+	err := tx.Get(&respondedAt, "SELECT responded_at FROM review_responses WHERE review_id = ?", response.ReviewID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && respondedAt.Before(response.RespondedAt) {
+		response.RespondedAt = respondedAt
+	}
+
+	_, err = tx.NamedExec(`
+		INSERT OR REPLACE INTO review_responses 
+		(review_id, app_id, body, source, responded_at, updated_at) 
+		VALUES (:review_id, :app_id, :body, :source, :responded_at, :updated_at)
+	`, response)
+	if err != nil {
+		return err
+	}
+
+	// Mirrored onto the review, which is what listings and exports show
+	_, err = tx.NamedExec(`
+		UPDATE reviews SET developer_response = :body, developer_response_date = :updated_at WHERE id = :review_id
+	`, response)
+	return err
+}
+
+func (r *SQLiteRepository) GetResponse(reviewID string) (*models.ReviewResponse, error) {
+	var response models.ReviewResponse
+	err := r.db.Get(&response, "SELECT * FROM review_responses WHERE review_id = ?", reviewID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (r *SQLiteRepository) GetResponseTimes(appID string, days int) ([]models.ReviewResponseTime, error) {
+	query := `
+		SELECT reviews.id AS review_id, reviews.rating, reviews.submitted_date, review_responses.responded_at
+		FROM reviews
+		LEFT JOIN review_responses ON review_responses.review_id = reviews.id
+		WHERE reviews.app_id = ? AND reviews.submitted_date >= datetime('now', '-' || ? || ' days')
+		ORDER BY reviews.submitted_date DESC
+	`
+
+	var times []models.ReviewResponseTime
+	err := r.db.Select(&times, query, appID, days)
+	return times, err
+}
+
 func (r *SQLiteRepository) GetAppConfig(appID string) (*models.AppConfig, error) {
 	var config struct {
 		AppID        string     `db:"app_id"`
 		PollInterval int64      `db:"poll_interval"`
 		LastPoll     *time.Time `db:"last_poll"`
 		IsActive     bool       `db:"is_active"`
+		Countries    string     `db:"countries"`
+		Adaptive     bool       `db:"adaptive"`
+		FeedFormat   string     `db:"feed_format"`
+		Platform     string     `db:"platform"`
+		Source       string     `db:"source"`
 	}
 
-	err := r.db.Get(&config, "SELECT * FROM app_configs WHERE app_id = ?", appID)
+	err := r.db.Get(&config, "SELECT app_id, poll_interval, last_poll, is_active, countries, adaptive, feed_format, platform, source FROM app_configs WHERE app_id = ?", appID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -131,6 +495,11 @@ func (r *SQLiteRepository) GetAppConfig(appID string) (*models.AppConfig, error)
 		PollInterval: time.Duration(config.PollInterval),
 		LastPoll:     config.LastPoll,
 		IsActive:     config.IsActive,
+		Countries:    splitCountries(config.Countries),
+		Adaptive:     config.Adaptive,
+		FeedFormat:   config.FeedFormat,
+		Platform:     config.Platform,
+		Source:       config.Source,
 	}, nil
 }
 
@@ -138,12 +507,26 @@ func (r *SQLiteRepository) UpsertAppConfig(config *models.AppConfig) error {
 
 	pol1Interval := int64(config.PollInterval)
 
+	feedFormat := config.FeedFormat
+	if feedFormat == "" {
+		feedFormat = models.FeedFormatJSON
+	}
+	platform := config.Platform
+	if platform == "" {
+		platform = models.PlatformIOS
+	}
+
 	query := `
 		INSERT OR REPLACE INTO app_configs 
-		(app_id, poll_interval, last_poll, is_active) 
-		VALUES (?, ?, ?, ?)
+		(app_id, poll_interval, last_poll, is_active, countries, adaptive, feed_format, platform, source) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := r.db.Exec(query, config.AppID, pol1Interval, config.LastPoll, config.IsActive)
+	_, err := r.db.Exec(query, config.AppID, pol1Interval, config.LastPoll, config.IsActive, joinCountries(config.Countries), config.Adaptive, feedFormat, platform, config.Source)
+	return err
+}
+
+func (r *SQLiteRepository) UpdateLastPoll(appID string, polledAt time.Time) error {
+	_, err := r.db.Exec("UPDATE app_configs SET last_poll = ? WHERE app_id = ?", polledAt, appID)
 	return err
 }
 
@@ -153,6 +536,286 @@ func (r *SQLiteRepository) GetActiveApps() ([]string, error) {
 	return appIDs, err
 }
 
+func (r *SQLiteRepository) UpsertAppMetadata(metadata *models.AppMetadata) error {
+	query := `
+		INSERT OR REPLACE INTO apps 
+		(app_id, name, artist, bundle_id, icon_url, category, store_url, updated_at) 
+		VALUES (:app_id, :name, :artist, :bundle_id, :icon_url, :category, :store_url, :updated_at)
+	`
+	_, err := r.db.NamedExec(query, metadata)
+	return err
+}
+
+func (r *SQLiteRepository) GetAppMetadata(appID string) (*models.AppMetadata, error) {
+	var metadata models.AppMetadata
+	err := r.db.Get(&metadata, "SELECT * FROM apps WHERE app_id = ?", appID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+func (r *SQLiteRepository) CreatePollRun(run *models.PollRun) error {
+	query := `
+		INSERT INTO poll_runs 
+		(app_id, started_at, duration_ms, attempts, http_status, pages, fetched, stored, updated, error, storefront_errors) 
+		VALUES (:app_id, :started_at, :duration_ms, :attempts, :http_status, :pages, :fetched, :stored, :updated, :error, :storefront_errors)
+	`
+	result, err := r.db.NamedExec(query, run)
+	if err != nil {
+		return err
+	}
+
+	run.ID, err = result.LastInsertId()
+	return err
+}
+
+func (r *SQLiteRepository) GetPollRuns(appID string, limit int) ([]models.PollRun, error) {
+	var runs []models.PollRun
+	err := r.db.Select(&runs, "SELECT * FROM poll_runs WHERE app_id = ? ORDER BY started_at DESC, id DESC LIMIT ?", appID, limit)
+	return runs, err
+}
+
+func (r *SQLiteRepository) GetCircuitBreaker(appID string) (*models.CircuitBreaker, error) {
+	var breaker models.CircuitBreaker
+	err := r.db.Get(&breaker, "SELECT * FROM circuit_breakers WHERE app_id = ?", appID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &breaker, nil
+}
+
+func (r *SQLiteRepository) UpsertCircuitBreaker(breaker *models.CircuitBreaker) error {
+	query := `
+		INSERT OR REPLACE INTO circuit_breakers 
+		(app_id, state, failures, opened_at, updated_at) 
+		VALUES (:app_id, :state, :failures, :opened_at, :updated_at)
+	`
+	_, err := r.db.NamedExec(query, breaker)
+	return err
+}
+
+func (r *SQLiteRepository) GetFeedCache(appID string) ([]models.FeedCache, error) {
+	var entries []models.FeedCache
+	err := r.db.Select(&entries, "SELECT * FROM feed_cache WHERE app_id = ?", appID)
+	return entries, err
+}
+
+func (r *SQLiteRepository) UpsertFeedCache(entry *models.FeedCache) error {
+	query := `
+		INSERT OR REPLACE INTO feed_cache 
+		(app_id, country, etag, last_modified, updated_at) 
+		VALUES (:app_id, :country, :etag, :last_modified, :updated_at)
+	`
+	_, err := r.db.NamedExec(query, entry)
+	return err
+}
+
+func (r *SQLiteRepository) QuarantineEntry(entry *models.QuarantinedEntry) error {
+	query := `
+		INSERT OR IGNORE INTO quarantined_entries 
+		(app_id, country, entry_id, reason, payload, checksum, quarantined_at) 
+		VALUES (:app_id, :country, :entry_id, :reason, :payload, :checksum, :quarantined_at)
+	`
+	_, err := r.db.NamedExec(query, entry)
+	return err
+}
+
+func (r *SQLiteRepository) GetQuarantinedEntries(filter QuarantineFilter) ([]models.QuarantinedEntry, error) {
+	query := "SELECT * FROM quarantined_entries WHERE 1 = 1"
+	var args []interface{}
+
+	if filter.AppID != "" {
+		query += " AND app_id = ?"
+		args = append(args, filter.AppID)
+	}
+	if !filter.IncludeResolved {
+		query += " AND resolved_at IS NULL"
+	}
+
+	query += " ORDER BY quarantined_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	var entries []models.QuarantinedEntry
+	err := r.db.Select(&entries, query, args...)
+	return entries, err
+}
+
+func (r *SQLiteRepository) ResolveQuarantinedEntry(id int64, resolvedAt time.Time) error {
+	_, err := r.db.Exec("UPDATE quarantined_entries SET resolved_at = ? WHERE id = ?", resolvedAt, id)
+	return err
+}
+
+func (r *SQLiteRepository) BeginTx(ctx context.Context) (Tx, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteTx{tx: tx}, nil
+}
+
 func (r *SQLiteRepository) Close() error {
 	return r.db.Close()
 }
+
+type sqliteTx struct {
+	tx *sqlx.Tx
+}
+
+func (t *sqliteTx) CreateReviews(ctx context.Context, reviews []models.Review) (int, error) {
+	stmt, err := t.tx.PrepareNamedContext(ctx, `
+		INSERT OR IGNORE INTO reviews 
+		(id, app_id, platform, country, author, rating, title, content, app_version, vote_sum, vote_count, author_uri, review_url, submitted_date, created_at, developer_response, developer_response_date) 
+		VALUES (:id, :app_id, :platform, :country, :author, :rating, :title, :content, :app_version, :vote_sum, :vote_count, :author_uri, :review_url, :submitted_date, :created_at, :developer_response, :developer_response_date)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	for i := range reviews {
+		review := &reviews[i]
+		setReviewDefaults(review)
+
+		result, err := stmt.ExecContext(ctx, review)
+		if err != nil {
+			return 0, fmt.Errorf("review %s: %w", review.ID, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		inserted += int(n)
+	}
+	return inserted, nil
+}
+
+func (t *sqliteTx) SaveReview(review *models.Review) (ReviewChange, error) {
+	return saveSQLiteReview(t.tx, review)
+}
+
+func (t *sqliteTx) StoredReviews(ids []string) (map[string]models.Review, error) {
+	return storedReviews(t.tx, ids)
+}
+
+func (t *sqliteTx) SaveResponse(response *models.ReviewResponse) error {
+	return saveSQLiteResponse(t.tx, response)
+}
+
+func (t *sqliteTx) ClearResponse(reviewID, source string) (bool, error) {
+	return clearResponse(t.tx, reviewID, source)
+}
+
+func (t *sqliteTx) UpdateLastPoll(appID string, polledAt time.Time) error {
+	_, err := t.tx.Exec("UPDATE app_configs SET last_poll = ? WHERE app_id = ?", polledAt, appID)
+	return err
+}
+
+func (t *sqliteTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqliteTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// clearResponse removes a review's reply synced from source from both
+// review_responses and the review itself. A reply recorded some other way,
+// such as through the API, is kept along with its copy on the review.
+func clearResponse(tx *sqlx.Tx, reviewID, source string) (bool, error) {
+	deleted, err := tx.Exec(tx.Rebind("DELETE FROM review_responses WHERE review_id = ? AND source = ?"), reviewID, source)
+	if err != nil {
+		return false, err
+	}
+	cleared, err := tx.Exec(tx.Rebind(`
+		UPDATE reviews SET developer_response = NULL, developer_response_date = NULL
+		WHERE id = ? AND developer_response IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM review_responses WHERE review_id = ?)
+	`), reviewID, reviewID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := deleted.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	m, err := cleared.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n+m > 0, nil
+}
+
+// storedReviewIDs looks ids up in one query, so callers can tell new reviews
+// from stored ones without a round trip each.
+func storedReviewIDs(q sqlx.Ext, ids []string) (map[string]bool, error) {
+	stored := make(map[string]bool)
+	if len(ids) == 0 {
+		return stored, nil
+	}
+
+	query, args, err := sqlx.In("SELECT id FROM reviews WHERE id IN (?)", ids)
+	if err != nil {
+		return nil, err
+	}
+	var found []string
+	if err := sqlx.Select(q, &found, q.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, id := range found {
+		stored[id] = true
+	}
+	return stored, nil
+}
+
+// storedReviews loads the stored versions of ids in one query, keyed by ID.
+func storedReviews(q sqlx.Ext, ids []string) (map[string]models.Review, error) {
+	stored := make(map[string]models.Review)
+	if len(ids) == 0 {
+		return stored, nil
+	}
+
+	query, args, err := sqlx.In("SELECT "+reviewColumns+" FROM reviews WHERE id IN (?)", ids)
+	if err != nil {
+		return nil, err
+	}
+	var found []models.Review
+	if err := sqlx.Select(q, &found, q.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, review := range found {
+		stored[review.ID] = review
+	}
+	return stored, nil
+}
+
+func joinCountries(countries []string) string {
+	if len(countries) == 0 {
+		return models.DefaultCountry
+	}
+	return strings.Join(countries, ",")
+}
+
+func splitCountries(s string) []string {
+	var countries []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			countries = append(countries, c)
+		}
+	}
+	if len(countries) == 0 {
+		return []string{models.DefaultCountry}
+	}
+	return countries
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+)
+
+// adaptiveBounds limits how far an adaptive poller may move its interval.
+type adaptiveBounds struct {
+	min       time.Duration
+	max       time.Duration
+	highWater int
+}
+
+// next returns the interval to use after run. Busy polls (at least highWater
+// new reviews) halve the interval, empty polls stretch it by half, anything in
+// between keeps it. Failed polls leave it alone so an outage doesn't look like
+// a quiet app.
+func (b adaptiveBounds) next(current time.Duration, run *models.PollRun) time.Duration {
+	if run == nil || run.Error != nil {
+		return current
+	}
+
+	next := current
+	switch {
+	case b.highWater > 0 && run.Stored >= b.highWater:
+		next = current / 2
+	case run.Stored == 0:
+		next = current + current/2
+	}
+
+	if b.min > 0 && next < b.min {
+		next = b.min
+	}
+	if b.max > 0 && next > b.max {
+		next = b.max
+	}
+	return next
+}
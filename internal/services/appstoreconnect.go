@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+const (
+	appStoreConnectAudience = "appstoreconnect-v1"
+	// appStoreConnectTokenTTL is the longest lifetime Apple accepts for an
+	// API token.
+	appStoreConnectTokenTTL = 20 * time.Minute
+	// appStoreConnectFullWalkInterval is how often an app's reviews are walked
+	// to the end instead of stopping at known ones. Replies are mostly posted
+	// to older reviews, which an ordinary poll never reaches.
+	appStoreConnectFullWalkInterval = 24 * time.Hour
+)
+
+// AppStoreConnectCredentials identify an App Store Connect API key: the key
+// ID and issuer ID shown under Users and Access, and the .p8 private key.
+type AppStoreConnectCredentials struct {
+	KeyID      string
+	IssuerID   string
+	PrivateKey string
+}
+
+// LoadAppStoreConnectCredentials reads the .p8 key downloaded from App Store
+// Connect.
+func LoadAppStoreConnectCredentials(keyID, issuerID, keyPath string) (*AppStoreConnectCredentials, error) {
+	if keyID == "" || issuerID == "" {
+		return nil, fmt.Errorf("App Store Connect key ID and issuer ID are required")
+	}
+
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	return &AppStoreConnectCredentials{KeyID: keyID, IssuerID: issuerID, PrivateKey: string(data)}, nil
+}
+
+// AppStoreConnectService is a ReviewSource reading an iOS app's reviews, and
+// our responses to them, from the App Store Connect customerReviews API. It
+// only serves apps belonging to the team that owns the API key.
+type AppStoreConnectService struct {
+	api     *storeAPI
+	logger  *logger.Logger
+	baseURL string
+	creds   AppStoreConnectCredentials
+	key     crypto.Signer
+	// fullWalkInterval is how often each app is walked to the end.
+	fullWalkInterval time.Duration
+
+	mu sync.Mutex
+	// fullWalks records when each app was last walked to the end.
+	fullWalks map[string]time.Time
+}
+
+func NewAppStoreConnectService(logger *logger.Logger, creds *AppStoreConnectCredentials) (*AppStoreConnectService, error) {
+	return NewAppStoreConnectServiceWithURL(logger, "https://api.appstoreconnect.apple.com", creds)
+}
+
+// NewAppStoreConnectServiceWithURL creates an App Store Connect source with a
+// custom API base URL (useful for testing).
+func NewAppStoreConnectServiceWithURL(logger *logger.Logger, baseURL string, creds *AppStoreConnectCredentials) (*AppStoreConnectService, error) {
+	key, err := parsePrivateKey([]byte(creds.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &AppStoreConnectService{
+		logger:           logger,
+		baseURL:          baseURL,
+		creds:            *creds,
+		key:              key,
+		fullWalkInterval: appStoreConnectFullWalkInterval,
+		fullWalks:        make(map[string]time.Time),
+	}
+	s.api = newStoreAPI(logger, "App Store Connect API", s.signToken)
+	return s, nil
+}
+
+func (s *AppStoreConnectService) Name() string {
+	return models.SourceAppStoreConnect
+}
+
+// customerReviewsPage is a page of the customerReviews response, with the
+// reviews' responses side-loaded under included. Reviews are kept raw so one
+// that fails to parse can be quarantined as served.
+type customerReviewsPage struct {
+	Data     []json.RawMessage `json:"data"`
+	Included []struct {
+		Type       string `json:"type"`
+		ID         string `json:"id"`
+		Attributes struct {
+			ResponseBody     string `json:"responseBody"`
+			LastModifiedDate string `json:"lastModifiedDate"`
+			State            string `json:"state"`
+		} `json:"attributes"`
+	} `json:"included"`
+	Links struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+// FetchWithRetry walks the app's reviews newest first by following
+// links.next, stopping early at a page of reviews that are all known. Once
+// every fullWalkInterval, and on the first poll after a restart, it walks to
+// the end instead so replies to older reviews are synced and deleted ones
+// cleared. Countries and Format don't apply; every territory is served
+// together.
+func (s *AppStoreConnectService) FetchWithRetry(ctx context.Context, appID string, opts FetchOptions, maxRetries int) (*FetchResult, error) {
+	started := time.Now()
+	full := s.fullWalkDue(appID, started)
+	if full {
+		opts.Known = nil
+	}
+
+	query := url.Values{
+		"sort":                            {"-createdDate"},
+		"limit":                           {"200"},
+		"include":                         {"response"},
+		"fields[customerReviewResponses]": {"responseBody,lastModifiedDate,state"},
+	}
+	first := fmt.Sprintf("%s/v1/apps/%s/customerReviews?%s", s.baseURL, url.PathEscape(appID), query.Encode())
+
+	result := &FetchResult{ReportsResponses: true}
+	err := s.api.walk(ctx, result, opts, opts.MaxPages, maxRetries, first, func(pageURL string) (*apiPage, int, error) {
+		var page customerReviewsPage
+		status, err := s.api.getJSON(ctx, pageURL, &page)
+		if err != nil {
+			return nil, status, err
+		}
+		reviews, quarantined := s.convertReviews(&page, appID)
+		return &apiPage{reviews: reviews, quarantined: quarantined, next: page.Links.Next}, status, nil
+	})
+	if full && err == nil {
+		s.mu.Lock()
+		s.fullWalks[appID] = started
+		s.mu.Unlock()
+	}
+	return result, err
+}
+
+// fullWalkDue reports whether an app hasn't been walked to the end within
+// fullWalkInterval of now.
+func (s *AppStoreConnectService) fullWalkDue(appID string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.fullWalks[appID]
+	return !ok || now.Sub(last) >= s.fullWalkInterval
+}
+
+// convertReviews converts a page's reviews, attaching our responses and
+// quarantining reviews that can't be decoded or whose date can't be read.
+func (s *AppStoreConnectService) convertReviews(page *customerReviewsPage, appID string) ([]models.Review, []models.QuarantinedEntry) {
+	type response struct {
+		body string
+		date *time.Time
+	}
+	responses := make(map[string]response)
+	for _, included := range page.Included {
+		if included.Type != "customerReviewResponses" {
+			continue
+		}
+		// Pending responses are stored too; they're answered as far as the
+		// reviewer's wait is concerned
+		r := response{body: included.Attributes.ResponseBody}
+		if date, err := time.Parse(time.RFC3339, included.Attributes.LastModifiedDate); err == nil {
+			r.date = &date
+		}
+		responses[included.ID] = r
+	}
+
+	var reviews []models.Review
+	var quarantined []models.QuarantinedEntry
+	for _, raw := range page.Data {
+		review, responseID, err := parseCustomerReview(raw, appID)
+		if err != nil {
+			entry := quarantinePayload(raw, appID, review.Country, customerReviewID(raw), err)
+			s.logger.Warn("Quarantining unparseable review", "app_id", appID, "review_id", entry.EntryID, "error", err)
+			quarantined = append(quarantined, entry)
+			continue
+		}
+		if r, ok := responses[responseID]; ok {
+			review.DeveloperResponse = &r.body
+			review.DeveloperResponseDate = r.date
+		}
+
+		reviews = append(reviews, review)
+	}
+	return reviews, quarantined
+}
+
+// customerReview is a single customerReviews resource.
+type customerReview struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Attributes struct {
+		Rating           int    `json:"rating"`
+		Title            string `json:"title"`
+		Body             string `json:"body"`
+		ReviewerNickname string `json:"reviewerNickname"`
+		CreatedDate      string `json:"createdDate"`
+		Territory        string `json:"territory"`
+	} `json:"attributes"`
+	Relationships struct {
+		Response struct {
+			Data *struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		} `json:"response"`
+	} `json:"relationships"`
+}
+
+// parseCustomerReview converts a raw customerReviews resource, returning the
+// ID of its response, if it has one. The review's country is set even when
+// parsing fails, so a quarantined review keeps its storefront.
+func parseCustomerReview(raw json.RawMessage, appID string) (models.Review, string, error) {
+	var data customerReview
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return models.Review{}, "", fmt.Errorf("invalid review: %w", err)
+	}
+
+	review := models.Review{
+		ID:        data.ID,
+		AppID:     appID,
+		Platform:  models.PlatformIOS,
+		Country:   territoryCountry(data.Attributes.Territory),
+		Author:    data.Attributes.ReviewerNickname,
+		Rating:    data.Attributes.Rating,
+		Content:   data.Attributes.Body,
+		CreatedAt: time.Now(),
+	}
+
+	submitted, err := time.Parse(time.RFC3339, data.Attributes.CreatedDate)
+	if err != nil {
+		return review, "", fmt.Errorf("invalid date format %q", data.Attributes.CreatedDate)
+	}
+	review.SubmittedDate = submitted
+
+	if title := data.Attributes.Title; title != "" {
+		review.Title = &title
+	}
+
+	var responseID string
+	if ref := data.Relationships.Response.Data; ref != nil {
+		responseID = ref.ID
+	}
+	return review, responseID, nil
+}
+
+// customerReviewID picks the review ID out of a raw customerReviews
+// resource, or returns "" for anything else.
+func customerReviewID(raw json.RawMessage) string {
+	var probe struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}
+	json.Unmarshal(raw, &probe)
+	if probe.Type != "customerReviews" {
+		return ""
+	}
+	return probe.ID
+}
+
+// signToken signs an API token for the longest lifetime Apple accepts.
+func (s *AppStoreConnectService) signToken(ctx context.Context) (string, time.Time, error) {
+	now := time.Now()
+	expiry := now.Add(appStoreConnectTokenTTL)
+	token, err := signJWT(s.key, s.creds.KeyID, map[string]any{
+		"iss": s.creds.IssuerID,
+		"aud": appStoreConnectAudience,
+		"iat": now.Unix(),
+		"exp": expiry.Unix(),
+	})
+	return token, expiry, err
+}
+
+// territoryCountry maps App Store Connect's ISO 3166 alpha-3 territory codes
+// to the lowercase alpha-2 storefront codes the RSS feed uses. Territories
+// missing from the table are stored lowercased as given.
+func territoryCountry(territory string) string {
+	if territory == "" {
+		return models.DefaultCountry
+	}
+	if country, ok := territoryCountries[strings.ToUpper(territory)]; ok {
+		return country
+	}
+	return strings.ToLower(territory)
+}
+
+var territoryCountries = map[string]string{
+	"ARE": "ae", "ARG": "ar", "AUS": "au", "AUT": "at", "BEL": "be",
+	"BGR": "bg", "BRA": "br", "CAN": "ca", "CHE": "ch", "CHL": "cl",
+	"CHN": "cn", "COL": "co", "CZE": "cz", "DEU": "de", "DNK": "dk",
+	"EGY": "eg", "ESP": "es", "FIN": "fi", "FRA": "fr", "GBR": "gb",
+	"GRC": "gr", "HKG": "hk", "HRV": "hr", "HUN": "hu", "IDN": "id",
+	"IND": "in", "IRL": "ie", "ISR": "il", "ITA": "it", "JPN": "jp",
+	"KAZ": "kz", "KOR": "kr", "MEX": "mx", "MYS": "my", "NGA": "ng",
+	"NLD": "nl", "NOR": "no", "NZL": "nz", "PAK": "pk", "PER": "pe",
+	"PHL": "ph", "POL": "pl", "PRT": "pt", "ROU": "ro", "SAU": "sa",
+	"SGP": "sg", "SVK": "sk", "SWE": "se", "THA": "th", "TUR": "tr",
+	"TWN": "tw", "UKR": "ua", "USA": "us", "VNM": "vn", "ZAF": "za",
+}
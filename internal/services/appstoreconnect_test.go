@@ -0,0 +1,370 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+	"github.com/youthtrouble/symmetrical-giggle/internal/repository"
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+// newFakeAppStoreConnect starts a stand-in for the customerReviews API of app
+// 123456 that only accepts ES256 tokens signed by the returned key, and
+// serves pages linked through links.next.
+func newFakeAppStoreConnect(t *testing.T, pages []string) (*fakeStoreAPI, *AppStoreConnectCredentials) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode key: %v", err)
+	}
+
+	path := "/v1/apps/123456/customerReviews"
+	api := newFakeStoreAPI(t, path, "cursor", pages, func(r *http.Request) error {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if err := verifyES256(token, &key.PublicKey); err != nil {
+			return fmt.Errorf("invalid token: %w", err)
+		}
+		if r.URL.Query().Get("include") != "response" {
+			return fmt.Errorf("expected responses to be included, got %q", r.URL.RawQuery)
+		}
+		return nil
+	}, func(baseURL string, page int) string {
+		return fmt.Sprintf("%s%s?cursor=page-%d&include=response", baseURL, path, page)
+	})
+
+	return api, &AppStoreConnectCredentials{
+		KeyID:      "KEY123",
+		IssuerID:   "issuer-uuid",
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}
+}
+
+func verifyES256(token string, key *ecdsa.PublicKey) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("expected 3 segments, got %d", len(parts))
+	}
+
+	var header map[string]string
+	var claims map[string]any
+	for i, v := range []any{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return err
+		}
+	}
+	if header["alg"] != "ES256" || header["kid"] != "KEY123" {
+		return fmt.Errorf("unexpected header %v", header)
+	}
+	if claims["iss"] != "issuer-uuid" || claims["aud"] != "appstoreconnect-v1" {
+		return fmt.Errorf("unexpected claims %v", claims)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	if len(signature) != 64 {
+		return fmt.Errorf("expected a 64 byte signature, got %d", len(signature))
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return fmt.Errorf("signature does not verify")
+	}
+	return nil
+}
+
+var appStoreConnectPages = []string{
+	`{"data":[
+		{"type":"customerReviews","id":"asc-1","attributes":{"rating":1,"title":"Crashes","body":"Crashes on launch","reviewerNickname":"Ana","createdDate":"2024-05-01T10:00:00-07:00","territory":"GBR"},
+		 "relationships":{"response":{"data":{"type":"customerReviewResponses","id":"resp-1"}}}},
+		{"type":"customerReviews","id":"asc-2","attributes":{"rating":5,"body":"Love it","reviewerNickname":"Ben","createdDate":"2024-04-30T09:00:00Z","territory":"USA"},
+		 "relationships":{"response":{"data":null}}}
+	],
+	"included":[{"type":"customerReviewResponses","id":"resp-1","attributes":{"responseBody":"Fixed in 2.0.1","lastModifiedDate":"2024-05-02T08:00:00Z","state":"PUBLISHED"}}],
+	"links":{"self":"ignored","next":"NEXT"}}`,
+	`{"data":[
+		{"type":"customerReviews","id":"asc-3","attributes":{"rating":3,"title":"OK","body":"Fine","reviewerNickname":"Cy","createdDate":"2024-04-01T09:00:00Z","territory":"ABW"}},
+		{"type":"customerReviews","id":"asc-4","attributes":{"rating":2,"body":"Meh","reviewerNickname":"Di","createdDate":"last spring","territory":"FRA"}}
+	],
+	"links":{"self":"ignored"}}`,
+}
+
+func TestAppStoreConnectService_FetchWithRetry(t *testing.T) {
+	server, creds := newFakeAppStoreConnect(t, appStoreConnectPages)
+
+	service, err := NewAppStoreConnectServiceWithURL(logger.New("error"), server.URL, creds)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	result, err := service.FetchWithRetry(context.Background(), "123456", FetchOptions{}, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Pages != 2 || len(result.Reviews) != 3 {
+		t.Fatalf("Expected 3 reviews over 2 pages, got %d over %d", len(result.Reviews), result.Pages)
+	}
+
+	review := result.Reviews[0]
+	if review.ID != "asc-1" || review.Platform != models.PlatformIOS || review.Country != "gb" || review.Rating != 1 {
+		t.Errorf("Unexpected review: %+v", review)
+	}
+	if review.Title == nil || *review.Title != "Crashes" || review.Author != "Ana" {
+		t.Errorf("Unexpected title/author: %v / %q", review.Title, review.Author)
+	}
+	if review.DeveloperResponse == nil || *review.DeveloperResponse != "Fixed in 2.0.1" {
+		t.Fatalf("Expected the developer response, got %v", review.DeveloperResponse)
+	}
+	if review.DeveloperResponseDate == nil || !review.DeveloperResponseDate.Equal(time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected response date %v", review.DeveloperResponseDate)
+	}
+
+	if unanswered := result.Reviews[1]; unanswered.DeveloperResponse != nil || unanswered.Title != nil {
+		t.Errorf("Expected no response or title, got %+v", unanswered)
+	}
+	if country := result.Reviews[2].Country; country != "abw" {
+		t.Errorf("Expected unknown territories to be lowercased, got %q", country)
+	}
+
+	// A review whose date can't be read is quarantined, and recovered once
+	// its payload parses
+	if len(result.Quarantined) != 1 || result.Quarantined[0].EntryID != "asc-4" || result.Quarantined[0].Country != "fr" {
+		t.Fatalf("Expected asc-4 quarantined in fr, got %+v", result.Quarantined)
+	}
+	fixed := result.Quarantined[0]
+	fixed.Payload = strings.Replace(fixed.Payload, `"last spring"`, `"2024-03-01T09:00:00Z"`, 1)
+	if recovered, err := reparse(fixed); err != nil || recovered.ID != "asc-4" || recovered.Country != "fr" || recovered.Rating != 2 {
+		t.Errorf("Expected asc-4 to reparse, got %+v (%v)", recovered, err)
+	}
+
+	_, err = service.FetchWithRetry(context.Background(), "999999", FetchOptions{}, 3)
+	if err == nil || !strings.Contains(err.Error(), "App Store Connect API returned status 404") {
+		t.Errorf("Expected a 404 from the API, got %v", err)
+	}
+}
+
+func TestPollingManager_PollsThroughConfiguredSource(t *testing.T) {
+	pages := append([]string(nil), appStoreConnectPages...)
+	server, creds := newFakeAppStoreConnect(t, pages)
+
+	log := logger.New("error")
+	appStoreConnect, err := NewAppStoreConnectServiceWithURL(log, server.URL, creds)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	// The RSS feed stays the default for iOS apps; only apps that select App
+	// Store Connect are polled through it
+	rss := NewRSSServiceWithURL(log, server.URL+"/rss")
+	repo, pm, poller := newSourcePollTest(t, "123456", models.PlatformIOS, models.SourceAppStoreConnect, rss, appStoreConnect)
+
+	run := pm.fetchAndStore(poller, true)
+	if run.Error != nil {
+		t.Fatalf("Expected no error, got %s", *run.Error)
+	}
+	if run.Stored != 3 {
+		t.Errorf("Expected 3 stored reviews, got %d", run.Stored)
+	}
+
+	stored, err := repo.GetReview("asc-1")
+	if err != nil || stored == nil {
+		t.Fatalf("Expected review asc-1 to be stored, got %v", err)
+	}
+	if stored.DeveloperResponse == nil || *stored.DeveloperResponse != "Fixed in 2.0.1" {
+		t.Errorf("Expected the developer response to be stored, got %v", stored.DeveloperResponse)
+	}
+
+	// The reply also counts as a tracked response
+	response, err := repo.GetResponse("asc-1")
+	if err != nil || response == nil {
+		t.Fatalf("Expected the response to be synced, got %v", err)
+	}
+	if response.Source != models.SourceAppStoreConnect || !response.RespondedAt.Equal(time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected synced response: %+v", response)
+	}
+	if !stored.Responded {
+		t.Error("Expected asc-1 to be marked responded")
+	}
+
+	// A reply recorded through the API survives polls that don't report it,
+	// along with when it was sent
+	respondedAt := time.Date(2024, 5, 3, 9, 0, 0, 0, time.UTC)
+	if err := repo.SaveResponse(&models.ReviewResponse{
+		ReviewID: "asc-2", AppID: "123456", Body: "Glad you like it", Source: models.ResponseSourceManual, RespondedAt: respondedAt, UpdatedAt: respondedAt,
+	}); err != nil {
+		t.Fatalf("Failed to save response: %v", err)
+	}
+	if run := pm.fetchAndStore(poller, true); run.Error != nil {
+		t.Fatalf("Expected no error, got %s", *run.Error)
+	}
+	manual, err := repo.GetResponse("asc-2")
+	if err != nil || manual == nil || manual.Source != models.ResponseSourceManual || !manual.RespondedAt.Equal(respondedAt) {
+		t.Fatalf("Expected the manual response to be kept, got %+v (%v)", manual, err)
+	}
+	if stored, err := repo.GetReview("asc-2"); err != nil || stored.DeveloperResponse == nil || *stored.DeveloperResponse != "Glad you like it" || !stored.Responded {
+		t.Errorf("Expected asc-2 to keep its reply, got %+v (%v)", stored, err)
+	}
+
+	// Deleting the reply in App Store Connect removes it here too
+	pages[0] = strings.Replace(pages[0], `{"data":{"type":"customerReviewResponses","id":"resp-1"}}`, `{"data":null}`, 1)
+	if run := pm.fetchAndStore(poller, true); run.Error != nil {
+		t.Fatalf("Expected no error, got %s", *run.Error)
+	}
+	stored, err = repo.GetReview("asc-1")
+	if err != nil || stored == nil {
+		t.Fatalf("Expected review asc-1 to be stored, got %v", err)
+	}
+	if stored.DeveloperResponse != nil || stored.DeveloperResponseDate != nil || stored.Responded {
+		t.Errorf("Expected the deleted reply to be cleared, got %+v", stored)
+	}
+	if response, err := repo.GetResponse("asc-1"); err != nil || response != nil {
+		t.Errorf("Expected no tracked response, got %+v (%v)", response, err)
+	}
+}
+
+func TestPollingManager_SyncsRepliesToOlderReviews(t *testing.T) {
+	pages := append([]string(nil), appStoreConnectPages...)
+	server, creds := newFakeAppStoreConnect(t, pages)
+
+	appStoreConnect, err := NewAppStoreConnectServiceWithURL(logger.New("error"), server.URL, creds)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	repo, pm, poller := newSourcePollTest(t, "123456", models.PlatformIOS, models.SourceAppStoreConnect, appStoreConnect)
+
+	poll := func(wantPages []int) {
+		t.Helper()
+		if run := pm.fetchAndStore(poller, true); run.Error != nil {
+			t.Fatalf("Expected no error, got %s", *run.Error)
+		}
+		if served := server.pagesServed(); fmt.Sprint(served) != fmt.Sprint(wantPages) {
+			t.Errorf("Expected pages %v to be fetched, got %v", wantPages, served)
+		}
+	}
+	backdateFullWalk := func() {
+		appStoreConnect.mu.Lock()
+		appStoreConnect.fullWalks["123456"] = time.Now().Add(-appStoreConnectFullWalkInterval)
+		appStoreConnect.mu.Unlock()
+	}
+
+	// The first poll walks every page
+	poll([]int{0, 1})
+
+	// A reply to asc-3 on page 2 is missed while page 1 has nothing new...
+	pages[1] = strings.Replace(pages[1], `"territory":"ABW"}}`, `"territory":"ABW"},"relationships":{"response":{"data":{"type":"customerReviewResponses","id":"resp-3"}}}}`, 1)
+	pages[1] = strings.Replace(pages[1], `"links":{"self":"ignored"}}`, `"included":[{"type":"customerReviewResponses","id":"resp-3","attributes":{"responseBody":"Thanks!","lastModifiedDate":"2024-04-03T08:00:00Z","state":"PUBLISHED"}}],"links":{"self":"ignored"}}`, 1)
+	poll([]int{0})
+	if response, err := repo.GetResponse("asc-3"); err != nil || response != nil {
+		t.Fatalf("Expected no response before a full walk, got %+v (%v)", response, err)
+	}
+
+	// ...and synced by the next full walk
+	backdateFullWalk()
+	poll([]int{0, 1})
+	stored, err := repo.GetReview("asc-3")
+	if err != nil || stored == nil {
+		t.Fatalf("Expected review asc-3 to be stored, got %v", err)
+	}
+	if stored.DeveloperResponse == nil || *stored.DeveloperResponse != "Thanks!" || !stored.Responded {
+		t.Errorf("Expected the reply to asc-3 to be synced, got %+v", stored)
+	}
+
+	// Deleting it is picked up the same way
+	pages[1] = appStoreConnectPages[1]
+	backdateFullWalk()
+	poll([]int{0, 1})
+	if response, err := repo.GetResponse("asc-3"); err != nil || response != nil {
+		t.Errorf("Expected the deleted reply to be cleared, got %+v (%v)", response, err)
+	}
+}
+
+// failingResponseTxRepository fails to record responses inside transactions.
+type failingResponseTxRepository struct {
+	repository.Repository
+}
+
+func (r failingResponseTxRepository) BeginTx(ctx context.Context) (repository.Tx, error) {
+	tx, err := r.Repository.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return failingResponseTx{tx}, nil
+}
+
+type failingResponseTx struct {
+	repository.Tx
+}
+
+func (t failingResponseTx) SaveResponse(response *models.ReviewResponse) error {
+	return errors.New("disk full")
+}
+
+func TestPollingManager_StoresRepliesWithReviews(t *testing.T) {
+	server, creds := newFakeAppStoreConnect(t, appStoreConnectPages)
+
+	log := logger.New("error")
+	appStoreConnect, err := NewAppStoreConnectServiceWithURL(log, server.URL, creds)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	repo, pm, poller := newSourcePollTest(t, "123456", models.PlatformIOS, models.SourceAppStoreConnect, appStoreConnect)
+
+	// A review stored with its reply but no tracked response still gets one,
+	// even though nothing else about it changed
+	result, err := appStoreConnect.FetchWithRetry(context.Background(), "123456", FetchOptions{MaxPages: 1}, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := repo.SaveReview(&result.Reviews[0]); err != nil {
+		t.Fatalf("Failed to save review: %v", err)
+	}
+	if run := pm.fetchAndStore(poller, true); run.Error != nil {
+		t.Fatalf("Expected no error, got %s", *run.Error)
+	}
+	if response, err := repo.GetResponse("asc-1"); err != nil || response == nil || response.Source != models.SourceAppStoreConnect {
+		t.Errorf("Expected the reply to asc-1 to be tracked, got %+v (%v)", response, err)
+	}
+
+	// A reply that can't be recorded takes the poll's reviews with it
+	other, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer other.Close()
+	failing := NewPollingManager(failingResponseTxRepository{other}, nil, config.PollingConfig{}, log)
+	failing.AddSource(appStoreConnect)
+
+	run := failing.fetchAndStore(poller, true)
+	if run.Error == nil || !strings.Contains(*run.Error, "disk full") {
+		t.Fatalf("Expected the failed response write on the run, got %v", run.Error)
+	}
+	if review, err := other.GetReview("asc-1"); err != nil || review != nil {
+		t.Errorf("Expected asc-1 not to be stored without its response, got %+v (%v)", review, err)
+	}
+}
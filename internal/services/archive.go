@@ -0,0 +1,277 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/repository"
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+// archiveTimeLayout names archived payloads so they sort by fetch time.
+const archiveTimeLayout = "20060102T150405.000000000"
+
+// FeedArchive stores every fetched feed page gzipped under
+// <dir>/<app>/<country>/<fetched-at>-page<n>.<format>.gz, so pages can be
+// replayed through the parser without asking Apple again.
+type FeedArchive struct {
+	dir string
+}
+
+func NewFeedArchive(dir string) *FeedArchive {
+	return &FeedArchive{dir: dir}
+}
+
+// ArchivedPayload identifies one archived feed page.
+type ArchivedPayload struct {
+	AppID     string
+	Country   string
+	Format    string
+	Page      int
+	FetchedAt time.Time
+	Path      string
+}
+
+// Write stores a feed page body. The file is written under a temporary name
+// and renamed, so a crash never leaves a truncated payload behind.
+func (a *FeedArchive) Write(appID, country, format string, page int, fetchedAt time.Time, body []byte) error {
+	for _, part := range []string{appID, country, format} {
+		if !validArchiveName(part) {
+			return fmt.Errorf("invalid archive path component %q", part)
+		}
+	}
+
+	dir := filepath.Join(a.dir, appID, country)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%sZ-page%d.%s.gz", fetchedAt.UTC().Format(archiveTimeLayout), page, format)
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	if _, err := zw.Write(body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compress payload: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compress payload: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write archive file: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+// validArchiveName reports whether name can be used as a single path
+// element without escaping the archive directory.
+func validArchiveName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// Prune deletes archived pages fetched before cutoff, and the storefront and
+// app directories it leaves empty. It returns the number of pages deleted.
+func (a *FeedArchive) Prune(cutoff time.Time) (int, error) {
+	payloads, err := a.List("")
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	dirs := make(map[string]bool)
+	for _, payload := range payloads {
+		if !payload.FetchedAt.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(payload.Path); err != nil && !os.IsNotExist(err) {
+			return pruned, err
+		}
+		pruned++
+		dirs[filepath.Dir(payload.Path)] = true
+	}
+
+	// Removing a directory that still holds files fails, which is fine
+	for dir := range dirs {
+		if os.Remove(dir) == nil {
+			os.Remove(filepath.Dir(dir))
+		}
+	}
+	return pruned, nil
+}
+
+// PruneEvery deletes pages older than retention straight away and then once
+// per interval, until ctx is done.
+func (a *FeedArchive) PruneEvery(ctx context.Context, interval, retention time.Duration, logger *logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pruned, err := a.Prune(time.Now().Add(-retention))
+		if err != nil {
+			logger.Warn("Failed to prune feed archive", "dir", a.dir, "error", err)
+		} else if pruned > 0 {
+			logger.Info("Pruned feed archive", "dir", a.dir, "pages", pruned, "retention", retention)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// List returns the archived pages for an app, or for every app when appID is
+// empty, newest first.
+func (a *FeedArchive) List(appID string) ([]ArchivedPayload, error) {
+	pattern := filepath.Join(a.dir, "*", "*", "*.gz")
+	if appID != "" {
+		pattern = filepath.Join(a.dir, appID, "*", "*.gz")
+	}
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var payloads []ArchivedPayload
+	for _, path := range paths {
+		payload, ok := parseArchivePath(path)
+		if !ok {
+			continue
+		}
+		payloads = append(payloads, payload)
+	}
+
+	sort.Slice(payloads, func(i, j int) bool {
+		if !payloads[i].FetchedAt.Equal(payloads[j].FetchedAt) {
+			return payloads[i].FetchedAt.After(payloads[j].FetchedAt)
+		}
+		return payloads[i].Page < payloads[j].Page
+	})
+	return payloads, nil
+}
+
+// Read returns the decompressed body of an archived page.
+func (a *FeedArchive) Read(payload ArchivedPayload) ([]byte, error) {
+	f, err := os.Open(payload.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", payload.Path, err)
+	}
+	defer zr.Close()
+
+	return io.ReadAll(zr)
+}
+
+func parseArchivePath(path string) (ArchivedPayload, bool) {
+	country := filepath.Base(filepath.Dir(path))
+	appID := filepath.Base(filepath.Dir(filepath.Dir(path)))
+
+	name := strings.TrimSuffix(filepath.Base(path), ".gz")
+	format := strings.TrimPrefix(filepath.Ext(name), ".")
+	stamp, page, ok := strings.Cut(strings.TrimSuffix(name, filepath.Ext(name)), "Z-page")
+	if !ok || format == "" {
+		return ArchivedPayload{}, false
+	}
+	fetchedAt, err := time.Parse(archiveTimeLayout, stamp)
+	if err != nil {
+		return ArchivedPayload{}, false
+	}
+	pageNum, err := strconv.Atoi(page)
+	if err != nil {
+		return ArchivedPayload{}, false
+	}
+
+	return ArchivedPayload{
+		AppID:     appID,
+		Country:   country,
+		Format:    format,
+		Page:      pageNum,
+		FetchedAt: fetchedAt,
+		Path:      path,
+	}, true
+}
+
+// ReplayResult counts what ReplayArchive did with the archived pages.
+type ReplayResult struct {
+	Payloads    int `json:"payloads"`
+	Failed      int `json:"failed"`
+	Reviews     int `json:"reviews"`
+	Inserted    int `json:"inserted"`
+	Backfilled  int `json:"backfilled"`
+	Quarantined int `json:"quarantined"`
+}
+
+// ReplayArchive re-runs the parser over archived pages for an app, or every
+// app when appID is empty, and stores the result. Reviews missing from the
+// database are inserted and stored ones only have empty columns filled in, so
+// replaying old pages never rolls back an edit or records a revision.
+func ReplayArchive(repo repository.Repository, rss *RSSService, archive *FeedArchive, appID string) (*ReplayResult, error) {
+	payloads, err := archive.List(appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive: %w", err)
+	}
+
+	result := &ReplayResult{}
+	for _, payload := range payloads {
+		result.Payloads++
+
+		body, err := archive.Read(payload)
+		if err != nil {
+			rss.logger.Warn("Failed to read archived page", "path", payload.Path, "error", err)
+			result.Failed++
+			continue
+		}
+
+		page, err := rss.decodePage(body, payload.Format, payload.AppID, payload.Country)
+		if err != nil {
+			rss.logger.Warn("Failed to parse archived page", "path", payload.Path, "error", err)
+			result.Failed++
+			continue
+		}
+
+		for _, review := range page.reviews {
+			result.Reviews++
+			change, err := repo.BackfillReview(&review)
+			if err != nil {
+				return result, fmt.Errorf("failed to store review %s: %w", review.ID, err)
+			}
+
+			switch change {
+			case repository.ReviewInserted:
+				result.Inserted++
+			case repository.ReviewUpdated:
+				result.Backfilled++
+			}
+		}
+
+		for _, entry := range page.quarantined {
+			if err := repo.QuarantineEntry(&entry); err != nil {
+				return result, fmt.Errorf("failed to quarantine entry %s: %w", entry.EntryID, err)
+			}
+			result.Quarantined++
+		}
+	}
+
+	return result, nil
+}
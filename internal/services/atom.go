@@ -0,0 +1,118 @@
+package services
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+)
+
+// atomFeed is the XML rendition of the customer-reviews feed. It carries the
+// same entries as the JSON feed, as elements and attributes rather than label
+// objects; the im: elements live in the http://itunes.apple.com/rss namespace.
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID struct {
+		Value    string `xml:",chardata"`
+		AppID    string `xml:"http://itunes.apple.com/rss id,attr"`
+		BundleID string `xml:"http://itunes.apple.com/rss bundleId,attr"`
+	} `xml:"id"`
+	Title    string `xml:"title"`
+	Contents []struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"content"`
+	Updated   string `xml:"updated"`
+	Rating    string `xml:"http://itunes.apple.com/rss rating"`
+	Version   string `xml:"http://itunes.apple.com/rss version"`
+	VoteSum   string `xml:"http://itunes.apple.com/rss voteSum"`
+	VoteCount string `xml:"http://itunes.apple.com/rss voteCount"`
+	Author    struct {
+		Name string `xml:"name"`
+		URI  string `xml:"uri"`
+	} `xml:"author"`
+	Links []struct {
+		Rel  string `xml:"rel,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+
+	// App metadata, only present on the feed's leading entry.
+	Name   string `xml:"http://itunes.apple.com/rss name"`
+	Artist string `xml:"http://itunes.apple.com/rss artist"`
+	Images []struct {
+		Height string `xml:"height,attr"`
+		Value  string `xml:",chardata"`
+	} `xml:"http://itunes.apple.com/rss image"`
+	Category struct {
+		Label string `xml:"label,attr"`
+	} `xml:"category"`
+}
+
+// decodeAtomFeed decodes the XML feed into the same shape as the JSON one, so
+// both go through parseReviews and produce identical reviews.
+func decodeAtomFeed(body []byte) (models.RSSFeed, error) {
+	var feed atomFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return models.RSSFeed{}, err
+	}
+
+	var rssData models.RSSFeed
+	for _, entry := range feed.Entries {
+		rssData.Feed.Entry = append(rssData.Feed.Entry, entry.toRSSEntry())
+	}
+	return rssData, nil
+}
+
+func (e atomEntry) toRSSEntry() models.RSSEntry {
+	var entry models.RSSEntry
+	entry.ID.Label = strings.TrimSpace(e.ID.Value)
+	entry.ID.Attributes.ID = e.ID.AppID
+	entry.ID.Attributes.BundleID = e.ID.BundleID
+	entry.Title.Label = strings.TrimSpace(e.Title)
+	entry.Content.Label = e.textContent()
+	entry.Updated.Label = strings.TrimSpace(e.Updated)
+	entry.Rating.Label = strings.TrimSpace(e.Rating)
+	entry.Version.Label = strings.TrimSpace(e.Version)
+	entry.VoteSum.Label = strings.TrimSpace(e.VoteSum)
+	entry.VoteCount.Label = strings.TrimSpace(e.VoteCount)
+	entry.Author.Name.Label = strings.TrimSpace(e.Author.Name)
+	entry.Author.URI.Label = strings.TrimSpace(e.Author.URI)
+
+	// Match the JSON decoder, which keeps the first link with an href
+	for _, link := range e.Links {
+		if link.Href != "" {
+			entry.Link.Attributes.Rel = link.Rel
+			entry.Link.Attributes.Href = link.Href
+			break
+		}
+	}
+
+	entry.Name.Label = strings.TrimSpace(e.Name)
+	entry.Artist.Label = strings.TrimSpace(e.Artist)
+	for _, image := range e.Images {
+		var img models.RSSImage
+		img.Label = strings.TrimSpace(image.Value)
+		img.Attributes.Height = image.Height
+		entry.Image = append(entry.Image, img)
+	}
+	entry.Category.Attributes.Label = e.Category.Label
+
+	return entry
+}
+
+// textContent returns the plain-text body. The XML feed also carries an HTML
+// rendering of the review, which the JSON feed doesn't.
+func (e atomEntry) textContent() string {
+	for _, content := range e.Contents {
+		if content.Type == "text" {
+			return content.Value
+		}
+	}
+	if len(e.Contents) > 0 {
+		return e.Contents[0].Value
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+)
+
+// breakerSettings drives the per-app circuit breaker. A closed circuit lets
+// every poll through; threshold consecutive failures open it, and while open
+// scheduled polls are skipped. Once cooldown has passed the circuit goes
+// half-open and the next poll is a trial: success closes it, failure reopens
+// it for another cooldown.
+type breakerSettings struct {
+	threshold int
+	cooldown  time.Duration
+}
+
+func newCircuitBreaker(appID string) *models.CircuitBreaker {
+	return &models.CircuitBreaker{AppID: appID, State: models.CircuitClosed, UpdatedAt: time.Now()}
+}
+
+// allow reports whether a scheduled poll may run, moving an open circuit to
+// half-open when its cooldown has elapsed. It returns true if the state changed.
+func (b breakerSettings) allow(breaker *models.CircuitBreaker, now time.Time) (allowed, changed bool) {
+	if breaker.State != models.CircuitOpen {
+		return true, false
+	}
+	if breaker.OpenedAt != nil && now.Before(breaker.OpenedAt.Add(b.cooldown)) {
+		return false, false
+	}
+
+	breaker.State = models.CircuitHalfOpen
+	breaker.UpdatedAt = now
+	return true, true
+}
+
+// record updates the breaker with a poll's outcome and returns true if the
+// state changed.
+func (b breakerSettings) record(breaker *models.CircuitBreaker, failed bool, now time.Time) bool {
+	before := *breaker
+
+	switch {
+	case !failed:
+		breaker.State = models.CircuitClosed
+		breaker.Failures = 0
+		breaker.OpenedAt = nil
+	case breaker.State == models.CircuitHalfOpen:
+		breaker.Failures++
+		breaker.State = models.CircuitOpen
+		breaker.OpenedAt = &now
+	default:
+		breaker.Failures++
+		if b.threshold > 0 && breaker.Failures >= b.threshold {
+			breaker.State = models.CircuitOpen
+			breaker.OpenedAt = &now
+		}
+	}
+
+	if breaker.State == before.State && breaker.Failures == before.Failures {
+		return false
+	}
+	breaker.UpdatedAt = now
+	return true
+}
+
+// retryAt is when an open circuit will next allow a trial poll.
+func (b breakerSettings) retryAt(breaker *models.CircuitBreaker) *time.Time {
+	if breaker.State != models.CircuitOpen || breaker.OpenedAt == nil {
+		return nil
+	}
+	at := breaker.OpenedAt.Add(b.cooldown)
+	return &at
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrBudgetExhausted is returned when a request can't get a token from the
+// outbound budget before its context deadline.
+var ErrBudgetExhausted = errors.New("outbound request budget exhausted")
+
+// requestBudget is a token bucket shared by every fetch an RSSService makes,
+// so adding apps or storefronts can't push us past Apple's throttling limits.
+type requestBudget struct {
+	limiter   *rate.Limiter
+	perMinute int
+
+	requests atomic.Int64
+	waits    atomic.Int64
+	waitTime atomic.Int64 // nanoseconds
+	rejected atomic.Int64
+}
+
+// OutboundMetrics reports how the outbound request budget is being used.
+type OutboundMetrics struct {
+	RequestsPerMinute int   `json:"requests_per_minute"`
+	Requests          int64 `json:"requests"`
+	Waits             int64 `json:"waits"`
+	WaitTimeMs        int64 `json:"wait_time_ms"`
+	Rejected          int64 `json:"rejected"`
+}
+
+func newRequestBudget(perMinute, burst int) *requestBudget {
+	if perMinute <= 0 {
+		return &requestBudget{limiter: rate.NewLimiter(rate.Inf, 0)}
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &requestBudget{
+		limiter:   rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), burst),
+		perMinute: perMinute,
+	}
+}
+
+// wait blocks until a token is available. Requests that would have to wait
+// past ctx's deadline are rejected straight away.
+func (b *requestBudget) wait(ctx context.Context) error {
+	started := time.Now()
+	if err := b.limiter.Wait(ctx); err != nil {
+		b.rejected.Add(1)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return ErrBudgetExhausted
+	}
+
+	b.requests.Add(1)
+	if waited := time.Since(started); waited > time.Millisecond {
+		b.waits.Add(1)
+		b.waitTime.Add(int64(waited))
+	}
+	return nil
+}
+
+func (b *requestBudget) metrics() OutboundMetrics {
+	return OutboundMetrics{
+		RequestsPerMinute: b.perMinute,
+		Requests:          b.requests.Load(),
+		Waits:             b.waits.Load(),
+		WaitTimeMs:        time.Duration(b.waitTime.Load()).Milliseconds(),
+		Rejected:          b.rejected.Load(),
+	}
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+const (
+	googlePlayScope = "https://www.googleapis.com/auth/androidpublisher"
+	// maxGooglePlayPages caps the pages walked per poll. The API only serves
+	// reviews from the last week, so this is rarely reached.
+	maxGooglePlayPages = 10
+)
+
+// GoogleCredentials is the part of a Google service account key file needed
+// to call the Google Play Developer API.
+type GoogleCredentials struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// LoadGoogleCredentials reads a service account key file downloaded from the
+// Google Cloud console.
+func LoadGoogleCredentials(path string) (*GoogleCredentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+
+	var creds GoogleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	}
+	if creds.ClientEmail == "" || creds.PrivateKey == "" {
+		return nil, fmt.Errorf("credentials in %s have no client_email or private_key", path)
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &creds, nil
+}
+
+// GooglePlayService is a ReviewSource reading an Android app's reviews from
+// the Google Play Developer API, keyed by package name.
+type GooglePlayService struct {
+	api     *storeAPI
+	logger  *logger.Logger
+	baseURL string
+	creds   GoogleCredentials
+	key     crypto.Signer
+}
+
+func NewGooglePlayService(logger *logger.Logger, creds *GoogleCredentials) (*GooglePlayService, error) {
+	return NewGooglePlayServiceWithURL(logger, "https://androidpublisher.googleapis.com", creds)
+}
+
+// NewGooglePlayServiceWithURL creates a Google Play source with a custom API
+// base URL (useful for testing). Tokens are requested from creds.TokenURI.
+func NewGooglePlayServiceWithURL(logger *logger.Logger, baseURL string, creds *GoogleCredentials) (*GooglePlayService, error) {
+	key, err := parsePrivateKey([]byte(creds.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &GooglePlayService{
+		logger:  logger,
+		baseURL: baseURL,
+		creds:   *creds,
+		key:     key,
+	}
+	s.api = newStoreAPI(logger, "Google Play API", s.requestToken)
+	return s, nil
+}
+
+func (s *GooglePlayService) Name() string {
+	return models.SourceGooglePlay
+}
+
+// playReviewsPage is a page of the reviews.list response. Reviews are kept
+// raw so one that fails to decode can be quarantined as served.
+type playReviewsPage struct {
+	Reviews         []json.RawMessage `json:"reviews"`
+	TokenPagination struct {
+		NextPageToken string `json:"nextPageToken"`
+	} `json:"tokenPagination"`
+}
+
+// playReview is a single review from reviews.list.
+type playReview struct {
+	ReviewID   string `json:"reviewId"`
+	AuthorName string `json:"authorName"`
+	Comments   []struct {
+		UserComment *struct {
+			Text            string        `json:"text"`
+			LastModified    playTimestamp `json:"lastModified"`
+			StarRating      int           `json:"starRating"`
+			AppVersionName  string        `json:"appVersionName"`
+			ThumbsUpCount   int           `json:"thumbsUpCount"`
+			ThumbsDownCount int           `json:"thumbsDownCount"`
+		} `json:"userComment"`
+	} `json:"comments"`
+}
+
+// playTimestamp is a protobuf Timestamp, whose seconds are JSON strings.
+type playTimestamp struct {
+	Seconds json.Number `json:"seconds"`
+	Nanos   int64       `json:"nanos"`
+}
+
+// FetchWithRetry walks the app's most recent review pages, stopping early at
+// a page of reviews that are all known. Countries and Format don't apply.
+func (s *GooglePlayService) FetchWithRetry(ctx context.Context, appID string, opts FetchOptions, maxRetries int) (*FetchResult, error) {
+	maxPages := opts.MaxPages
+	if maxPages <= 0 || maxPages > maxGooglePlayPages {
+		maxPages = maxGooglePlayPages
+	}
+
+	result := &FetchResult{}
+	err := s.api.walk(ctx, result, opts, maxPages, maxRetries, "", func(pageToken string) (*apiPage, int, error) {
+		query := url.Values{"maxResults": {"100"}}
+		if pageToken != "" {
+			query.Set("token", pageToken)
+		}
+		endpoint := fmt.Sprintf("%s/androidpublisher/v3/applications/%s/reviews?%s", s.baseURL, url.PathEscape(appID), query.Encode())
+
+		var page playReviewsPage
+		status, err := s.api.getJSON(ctx, endpoint, &page)
+		if err != nil {
+			return nil, status, err
+		}
+		reviews, quarantined := s.convertReviews(&page, appID)
+		return &apiPage{reviews: reviews, quarantined: quarantined, next: page.TokenPagination.NextPageToken}, status, nil
+	})
+	return result, err
+}
+
+// convertReviews converts a page's reviews, quarantining those that can't be
+// decoded or whose timestamp can't be read.
+func (s *GooglePlayService) convertReviews(page *playReviewsPage, appID string) ([]models.Review, []models.QuarantinedEntry) {
+	var reviews []models.Review
+	var quarantined []models.QuarantinedEntry
+	for _, raw := range page.Reviews {
+		review, ok, err := parsePlayReview(raw, appID)
+		if err != nil {
+			entry := quarantinePayload(raw, appID, "", playReviewID(raw), err)
+			s.logger.Warn("Quarantining unparseable review", "app_id", appID, "review_id", entry.EntryID, "error", err)
+			quarantined = append(quarantined, entry)
+			continue
+		}
+		if ok {
+			reviews = append(reviews, review)
+		}
+	}
+	return reviews, quarantined
+}
+
+// parsePlayReview converts a raw reviews.list review. It reports false for
+// reviews without a user comment, which have nothing to store.
+func parsePlayReview(raw json.RawMessage, appID string) (models.Review, bool, error) {
+	var r playReview
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return models.Review{}, false, fmt.Errorf("invalid review: %w", err)
+	}
+
+	// Developer replies share the comments list with the review itself
+	for _, comment := range r.Comments {
+		user := comment.UserComment
+		if user == nil {
+			continue
+		}
+
+		seconds, err := user.LastModified.Seconds.Int64()
+		if err != nil {
+			return models.Review{}, false, fmt.Errorf("invalid timestamp %q", user.LastModified.Seconds)
+		}
+
+		// Reviews written with a separate title come back as title<TAB>body
+		var title *string
+		content := user.Text
+		if before, after, ok := strings.Cut(user.Text, "\t"); ok {
+			content = after
+			if before != "" {
+				title = &before
+			}
+		}
+
+		// Play reviews aren't tied to a storefront, so Country stays empty
+		return models.Review{
+			ID:            r.ReviewID,
+			AppID:         appID,
+			Platform:      models.PlatformAndroid,
+			Author:        r.AuthorName,
+			Rating:        user.StarRating,
+			Title:         title,
+			Content:       content,
+			AppVersion:    user.AppVersionName,
+			VoteSum:       user.ThumbsUpCount,
+			VoteCount:     user.ThumbsUpCount + user.ThumbsDownCount,
+			ReviewURL:     fmt.Sprintf("https://play.google.com/store/apps/details?id=%s&reviewId=%s", url.QueryEscape(appID), url.QueryEscape(r.ReviewID)),
+			SubmittedDate: time.Unix(seconds, user.LastModified.Nanos),
+			CreatedAt:     time.Now(),
+		}, true, nil
+	}
+	return models.Review{}, false, nil
+}
+
+// playReviewID picks the review ID out of a raw review, or returns "" when
+// it has none.
+func playReviewID(raw json.RawMessage) string {
+	var probe struct {
+		ReviewID string `json:"reviewId"`
+	}
+	json.Unmarshal(raw, &probe)
+	return probe.ReviewID
+}
+
+// requestToken exchanges a signed service account assertion for an OAuth
+// token.
+func (s *GooglePlayService) requestToken(ctx context.Context) (string, time.Time, error) {
+	now := time.Now()
+	assertion, err := signJWT(s.key, s.creds.PrivateKeyID, map[string]any{
+		"iss":   s.creds.ClientEmail,
+		"scope": googlePlayScope,
+		"aud":   s.creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.creds.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.api.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, &FeedError{StatusCode: resp.StatusCode, Source: "Google token endpoint"}
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to decode access token: %w", err)
+	}
+
+	return token.AccessToken, now.Add(time.Duration(token.ExpiresIn) * time.Second), nil
+}
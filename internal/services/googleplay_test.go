@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+	"github.com/youthtrouble/symmetrical-giggle/internal/repository"
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+// newFakeGooglePlay starts a server standing in for both the OAuth token
+// endpoint and the reviews API, serving pages of reviews for com.example.app,
+// and returns service account credentials it accepts.
+func newFakeGooglePlay(t *testing.T, pages []string) (*fakeStoreAPI, *GoogleCredentials) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode key: %v", err)
+	}
+
+	api := newFakeStoreAPI(t, "/androidpublisher/v3/applications/com.example.app/reviews", "token", pages, func(r *http.Request) error {
+		if r.Header.Get("Authorization") != "Bearer play-token" {
+			return fmt.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		return nil
+	}, func(_ string, page int) string {
+		return fmt.Sprintf("page-%d", page)
+	})
+	api.mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("Unexpected grant type %q", r.PostForm.Get("grant_type"))
+		}
+		if err := verifyRS256(r.PostForm.Get("assertion"), &key.PublicKey); err != nil {
+			t.Errorf("Invalid assertion: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "play-token", "expires_in": 3600})
+	})
+
+	return api, &GoogleCredentials{
+		ClientEmail: "reviews@example.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    api.URL + "/token",
+	}
+}
+
+func verifyRS256(token string, key *rsa.PublicKey) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("expected 3 segments, got %d", len(parts))
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+}
+
+var googlePlayPages = []string{
+	`{"reviews":[
+		{"reviewId":"gp-1","authorName":"Ana","comments":[
+			{"userComment":{"text":"Great\tLove the new widgets","lastModified":{"seconds":"1714582800","nanos":0},"starRating":5,"appVersionName":"3.1.0","thumbsUpCount":4,"thumbsDownCount":1}},
+			{"developerComment":{"text":"Thanks!","lastModified":{"seconds":"1714590000"}}}
+		]}
+	],"tokenPagination":{"nextPageToken":"NEXT"}}`,
+	`{"reviews":[
+		{"reviewId":"gp-3","authorName":"Cy","comments":[
+			{"userComment":{"text":"From the future","lastModified":{"seconds":"1.7e9"},"starRating":3}}
+		]}
+	],"tokenPagination":{"nextPageToken":"NEXT"}}`,
+	`{"reviews":[
+		{"reviewId":"gp-2","authorName":"Ben","comments":[
+			{"userComment":{"text":"Keeps crashing","lastModified":{"seconds":"1714496400"},"starRating":1,"appVersionName":"3.0.2"}}
+		]}
+	]}`,
+}
+
+func TestGooglePlayService_FetchWithRetry(t *testing.T) {
+	server, creds := newFakeGooglePlay(t, googlePlayPages)
+
+	service, err := NewGooglePlayServiceWithURL(logger.New("error"), server.URL, creds)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	result, err := service.FetchWithRetry(context.Background(), "com.example.app", FetchOptions{}, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The second page's only review is quarantined, which doesn't end the walk
+	if result.Pages != 3 || len(result.Reviews) != 2 {
+		t.Fatalf("Expected 2 reviews over 3 pages, got %d over %d", len(result.Reviews), result.Pages)
+	}
+
+	review := result.Reviews[0]
+	if review.ID != "gp-1" || review.Platform != models.PlatformAndroid || review.Rating != 5 {
+		t.Errorf("Unexpected review: %+v", review)
+	}
+	if review.Title == nil || *review.Title != "Great" || review.Content != "Love the new widgets" {
+		t.Errorf("Expected title and body split on the tab, got %v / %q", review.Title, review.Content)
+	}
+	if review.AppVersion != "3.1.0" || review.VoteSum != 4 || review.VoteCount != 5 {
+		t.Errorf("Unexpected version/votes: %s %d/%d", review.AppVersion, review.VoteSum, review.VoteCount)
+	}
+	if !review.SubmittedDate.Equal(time.Unix(1714582800, 0)) {
+		t.Errorf("Unexpected submitted date %s", review.SubmittedDate)
+	}
+	if result.Reviews[1].Title != nil {
+		t.Errorf("Expected no title, got %q", *result.Reviews[1].Title)
+	}
+	if review.Country != "" {
+		t.Errorf("Expected no storefront for a Play review, got %q", review.Country)
+	}
+	if len(result.Quarantined) != 1 || result.Quarantined[0].EntryID != "gp-3" || !strings.Contains(result.Quarantined[0].Reason, "timestamp") {
+		t.Errorf("Expected gp-3 quarantined for its timestamp, got %+v", result.Quarantined)
+	}
+
+	_, err = service.FetchWithRetry(context.Background(), "com.example.missing", FetchOptions{}, 3)
+	if err == nil || !strings.Contains(err.Error(), "Google Play API returned status 404") {
+		t.Errorf("Expected a 404 from the API, got %v", err)
+	}
+}
+
+func TestPollingManager_PollsThroughPlatformSource(t *testing.T) {
+	server, creds := newFakeGooglePlay(t, googlePlayPages)
+
+	repo, pm, poller := newSourcePollTest(t, "com.example.app", models.PlatformAndroid, "")
+	run := pm.fetchAndStore(poller, true)
+	if run.Error == nil {
+		t.Fatal("Expected an error without a Google Play source")
+	}
+	if poller.circuit.Failures != 0 {
+		t.Errorf("A missing source shouldn't count against the breaker, got %d failures", poller.circuit.Failures)
+	}
+
+	log := logger.New("error")
+	googlePlay, err := NewGooglePlayServiceWithURL(log, server.URL, creds)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	pm.AddSource(googlePlay)
+
+	run = pm.fetchAndStore(poller, true)
+	if run.Error != nil {
+		t.Fatalf("Expected no error, got %s", *run.Error)
+	}
+	if run.Stored != 2 {
+		t.Errorf("Expected 2 stored reviews, got %d", run.Stored)
+	}
+
+	stored, err := repo.GetReview("gp-2")
+	if err != nil || stored == nil {
+		t.Fatalf("Expected review gp-2 to be stored, got %v", err)
+	}
+	if stored.Platform != models.PlatformAndroid || stored.AppID != "com.example.app" || stored.Country != "" {
+		t.Errorf("Unexpected stored review: %+v", stored)
+	}
+
+	// The quarantined review is recovered once its payload parses
+	entries, err := repo.GetQuarantinedEntries(repository.QuarantineFilter{AppID: "com.example.app"})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected 1 quarantined review, got %d (%v)", len(entries), err)
+	}
+	fixed := strings.Replace(entries[0].Payload, `"1.7e9"`, `"1700000000"`, 1)
+	if err := repo.QuarantineEntry(&models.QuarantinedEntry{
+		AppID: "com.example.app", EntryID: "gp-3", Reason: "test", Payload: fixed, Checksum: "fixed", QuarantinedAt: time.Now(),
+	}); err != nil {
+		t.Fatalf("Failed to quarantine entry: %v", err)
+	}
+	result, err := ReprocessQuarantine(repo, log, "com.example.app")
+	if err != nil {
+		t.Fatalf("Failed to reprocess: %v", err)
+	}
+	if result.Checked != 2 || result.Recovered != 1 {
+		t.Errorf("Expected 1 of 2 entries recovered, got %+v", result)
+	}
+	if recovered, err := repo.GetReview("gp-3"); err != nil || recovered == nil || recovered.Platform != models.PlatformAndroid {
+		t.Errorf("Expected gp-3 stored as an Android review, got %+v (%v)", recovered, err)
+	}
+}
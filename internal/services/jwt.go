@@ -0,0 +1,104 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// parsePrivateKey reads a PEM-encoded PKCS#8 private key, the format of both
+// Google service account keys and App Store Connect .p8 keys.
+func parsePrivateKey(pemData []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("no PEM block found in private key")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// signJWT returns a compact JWT over claims. RSA keys sign with RS256 and P-256
+// keys with ES256; keyID, when set, is sent as the kid header.
+func signJWT(key crypto.Signer, keyID string, claims map[string]any) (string, error) {
+	header := map[string]string{"typ": "JWT"}
+	if keyID != "" {
+		header["kid"] = keyID
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		header["alg"] = "RS256"
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return "", fmt.Errorf("unsupported curve %s for ES256", k.Curve.Params().Name)
+		}
+		header["alg"] = "ES256"
+	default:
+		return "", fmt.Errorf("unsupported signing key type %T", key)
+	}
+
+	encodedHeader, err := encodeJWTSegment(header)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := encodeJWTSegment(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodedHeader + "." + encodedClaims
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		if signature, err = ecdsaJWTSignature(signature); err != nil {
+			return "", err
+		}
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func encodeJWTSegment(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ecdsaJWTSignature converts the ASN.1 signature crypto.Signer produces into
+// the fixed-width r||s form JWS expects for ES256.
+func ecdsaJWTSignature(der []byte) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("failed to decode ECDSA signature: %w", err)
+	}
+
+	out := make([]byte, 64)
+	sig.R.FillBytes(out[:32])
+	sig.S.FillBytes(out[32:])
+	return out, nil
+}
@@ -2,44 +2,224 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
 	"sync"
 	"time"
 
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
 	"github.com/youthtrouble/symmetrical-giggle/internal/models"
 	"github.com/youthtrouble/symmetrical-giggle/internal/repository"
 	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
 )
 
 type PollingManager struct {
-	repo       repository.Repository
-	rssService *RSSService
-	logger     *logger.Logger
-	pollers    map[string]*AppPoller
-	mu         sync.RWMutex
-	ctx        context.Context
-	cancel     context.CancelFunc
+	repo          repository.Repository
+	sources       map[string]ReviewSource
+	logger        *logger.Logger
+	maxConcurrent int
+	startupJitter time.Duration
+	adaptive      adaptiveBounds
+	breaker       breakerSettings
+	pollers       map[string]*AppPoller
+	queue         []*AppPoller // due pollers waiting for a free worker
+	inFlight      map[string]*PollCall
+	running       int
+	mu            sync.RWMutex
+	jobs          chan *AppPoller
+	wake          chan struct{}
+	startOnce     sync.Once
+	workers       sync.WaitGroup
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+type pollerState int
+
+const (
+	pollerIdle pollerState = iota
+	pollerQueued
+	pollerRunning
+)
+
+func (s pollerState) String() string {
+	switch s {
+	case pollerQueued:
+		return "queued"
+	case pollerRunning:
+		return "running"
+	default:
+		return "idle"
+	}
 }
 
 type AppPoller struct {
-	appID    string
-	interval time.Duration
-	ticker   *time.Ticker
-	done     chan struct{}
+	appID     string
+	interval  time.Duration
+	countries []string
+	adaptive  bool
+	format    string
+	platform  string
+	source    string
+
+	// Scheduling fields, guarded by PollingManager.mu.
+	state   pollerState
+	nextRun time.Time
+	stopped bool
+	// effective is the interval actually used between polls; it only
+	// differs from interval for adaptive pollers.
+	effective time.Duration
+
+	mu      sync.Mutex
+	stats   pollerStats
+	circuit *models.CircuitBreaker
+}
+
+// pollerStats tracks the outcome of an app's recent polls, guarded by AppPoller.mu.
+type pollerStats struct {
+	lastPages           int
+	lastAttempt         *time.Time
+	lastSuccess         *time.Time
+	lastError           *string
+	consecutiveFailures int
+}
+
+// PollingStatus is a snapshot of the scheduler and every registered poller.
+type PollingStatus struct {
+	Workers    int                         `json:"workers"`
+	Running    int                         `json:"running"`
+	QueueDepth int                         `json:"queue_depth"`
+	Outbound   OutboundMetrics             `json:"outbound"`
+	Apps       map[string]AppPollingStatus `json:"apps"`
+}
+
+type AppPollingStatus struct {
+	Interval            string        `json:"interval"`
+	Adaptive            bool          `json:"adaptive"`
+	EffectiveInterval   string        `json:"effective_interval"`
+	Platform            string        `json:"platform"`
+	Source              string        `json:"source"`
+	Countries           []string      `json:"countries"`
+	FeedFormat          string        `json:"feed_format"`
+	State               string        `json:"state"`
+	NextRun             time.Time     `json:"next_run"`
+	LastAttempt         *time.Time    `json:"last_attempt"`
+	LastSuccess         *time.Time    `json:"last_success"`
+	LastError           *string       `json:"last_error"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	LastPages           int           `json:"last_pages"`
+	TotalReviews        int           `json:"total_reviews"`
+	Circuit             CircuitStatus `json:"circuit"`
+	Active              bool          `json:"active"`
+}
+
+type CircuitStatus struct {
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"opened_at"`
+	RetryAt  *time.Time `json:"retry_at"`
+}
+
+var (
+	// ErrAppNotPolled is returned by PollNow for apps without an active poller.
+	ErrAppNotPolled = errors.New("app is not being polled")
+	// ErrShuttingDown is returned by PollNow once StopAll has been called.
+	ErrShuttingDown = errors.New("polling is shutting down")
+)
+
+// PollCall is a poll in progress that any number of callers can wait on.
+type PollCall struct {
+	done   chan struct{}
+	run    *models.PollRun
+	manual bool
+	// skipped holds the breaker state when an open circuit skipped the poll.
+	skipped *models.CircuitBreaker
+	// started is set once a worker picks the poll up, guarded by PollingManager.mu.
+	started bool
+}
+
+// Done is closed once the poll has finished.
+func (c *PollCall) Done() <-chan struct{} {
+	return c.done
+}
+
+// Run returns the finished poll's record, or nil while it is still in flight,
+// when the app's circuit was open or when the app stopped being polled before
+// the poll ran.
+func (c *PollCall) Run() *models.PollRun {
+	select {
+	case <-c.done:
+		return c.run
+	default:
+		return nil
+	}
+}
+
+// Skipped returns the app's breaker state when the finished poll was a
+// scheduled one skipped because the circuit was open, and nil otherwise.
+func (c *PollCall) Skipped() *models.CircuitBreaker {
+	select {
+	case <-c.done:
+		return c.skipped
+	default:
+		return nil
+	}
 }
 
-func NewPollingManager(repo repository.Repository, rssService *RSSService, logger *logger.Logger) *PollingManager {
+// NewPollingManager creates a manager that polls apps through source. Other
+// sources are registered with AddSource.
+func NewPollingManager(repo repository.Repository, source ReviewSource, cfg config.PollingConfig, logger *logger.Logger) *PollingManager {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+
+	sources := make(map[string]ReviewSource)
+	if source != nil {
+		sources[source.Name()] = source
+	}
+
 	return &PollingManager{
-		repo:       repo,
-		rssService: rssService,
-		logger:     logger,
-		pollers:    make(map[string]*AppPoller),
-		ctx:        ctx,
-		cancel:     cancel,
+		repo:          repo,
+		sources:       sources,
+		logger:        logger,
+		maxConcurrent: maxConcurrent,
+		startupJitter: cfg.StartupJitter,
+		adaptive:      adaptiveBounds{min: cfg.MinInterval, max: cfg.MaxInterval, highWater: cfg.AdaptiveHighWater},
+		breaker:       breakerSettings{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown},
+		pollers:       make(map[string]*AppPoller),
+		inFlight:      make(map[string]*PollCall),
+		jobs:          make(chan *AppPoller),
+		wake:          make(chan struct{}, 1),
+		ctx:           ctx,
+		cancel:        cancel,
 	}
 }
 
+// AddSource registers the source polled for apps selecting source.Name(),
+// replacing any previous one.
+func (pm *PollingManager) AddSource(source ReviewSource) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.sources[source.Name()] = source
+}
+
+// sourceFor returns the source the poller's app selected, or its platform's
+// default, if that source is registered.
+func (pm *PollingManager) sourceFor(poller *AppPoller) (string, ReviewSource) {
+	name := poller.source
+	if name == "" {
+		name = models.DefaultSource(poller.platform)
+	}
+
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return name, pm.sources[name]
+}
+
 func (pm *PollingManager) StartAll() error {
 	activeApps, err := pm.repo.GetActiveApps()
 	if err != nil {
@@ -65,8 +245,8 @@ func (pm *PollingManager) StartAll() error {
 		}
 
 		if config != nil && config.IsActive && config.PollInterval > 0 {
-			pm.logger.Info("Starting polling for app", "app_id", appID, "interval", config.PollInterval)
-			pm.StartPolling(appID, config.PollInterval)
+			pm.logger.Info("Starting polling for app", "app_id", appID, "interval", config.PollInterval, "last_poll", config.LastPoll)
+			pm.startPoller(config, pm.resumeAt(config, time.Now()))
 		} else if config != nil && config.IsActive && config.PollInterval <= 0 {
 			pm.logger.Warn("Skipping app with invalid polling interval", "app_id", appID, "interval", config.PollInterval)
 		} else if config == nil {
@@ -80,12 +260,45 @@ func (pm *PollingManager) StartAll() error {
 	return nil
 }
 
-func (pm *PollingManager) StartPolling(appID string, interval time.Duration) {
+// StartPolling (re)registers an app and polls it straight away.
+func (pm *PollingManager) StartPolling(config *models.AppConfig) {
+	pm.startPoller(config, time.Now())
+}
+
+// resumeAt picks an app's first run after a restart: its regular slot when
+// that is still ahead, otherwise a random point within the startup jitter so
+// overdue apps don't all fetch at once.
+func (pm *PollingManager) resumeAt(config *models.AppConfig, now time.Time) time.Time {
+	if config.LastPoll != nil {
+		due := config.LastPoll.Add(config.PollInterval)
+		if due.After(now) {
+			return due
+		}
+	}
+
+	window := pm.startupJitter
+	if window > config.PollInterval {
+		window = config.PollInterval
+	}
+	if window <= 0 {
+		return now
+	}
+	return now.Add(rand.N(window))
+}
+
+func (pm *PollingManager) startPoller(config *models.AppConfig, nextRun time.Time) {
+	appID, interval := config.AppID, config.PollInterval
+
+	pm.startOnce.Do(pm.startWorkers)
+
+	stats := pm.seedStats(config)
+	circuit := pm.loadCircuit(appID)
+
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
 	if poller, exists := pm.pollers[appID]; exists {
-		pm.stopPoller(poller)
+		pm.removePoller(poller)
 	}
 
 	if interval <= 0 {
@@ -93,18 +306,43 @@ func (pm *PollingManager) StartPolling(appID string, interval time.Duration) {
 		return
 	}
 
+	countries := config.Countries
+	if len(countries) == 0 {
+		countries = []string{models.DefaultCountry}
+	}
+
+	format := config.FeedFormat
+	if format == "" {
+		format = models.FeedFormatJSON
+	}
+
+	platform := config.Platform
+	if platform == "" {
+		platform = models.PlatformIOS
+	}
+	source := config.Source
+	if source == "" {
+		source = models.DefaultSource(platform)
+	}
+
 	poller := &AppPoller{
-		appID:    appID,
-		interval: interval,
-		ticker:   time.NewTicker(interval),
-		done:     make(chan struct{}),
+		appID:     appID,
+		interval:  interval,
+		countries: append([]string(nil), countries...),
+		adaptive:  config.Adaptive,
+		format:    format,
+		platform:  platform,
+		source:    source,
+		nextRun:   nextRun,
+		effective: interval,
+		stats:     stats,
+		circuit:   circuit,
 	}
 
 	pm.pollers[appID] = poller
+	pm.signal()
 
-	go pm.pollApp(poller)
-
-	pm.logger.Info("Started polling", "app_id", appID, "interval", interval)
+	pm.logger.Info("Started polling", "app_id", appID, "platform", poller.platform, "source", poller.source, "interval", interval, "adaptive", poller.adaptive, "countries", poller.countries, "format", poller.format, "next_run", nextRun)
 }
 
 func (pm *PollingManager) StopPolling(appID string) {
@@ -112,98 +350,496 @@ func (pm *PollingManager) StopPolling(appID string) {
 	defer pm.mu.Unlock()
 
 	if poller, exists := pm.pollers[appID]; exists {
-		pm.stopPoller(poller)
-		delete(pm.pollers, appID)
+		pm.removePoller(poller)
 		pm.logger.Info("Stopped polling", "app_id", appID)
 	}
 }
 
+// StopAll stops scheduling, cancels in-flight fetches and waits for the workers to exit.
 func (pm *PollingManager) StopAll() {
 	pm.cancel()
 
 	pm.mu.Lock()
-	defer pm.mu.Unlock()
+	for _, poller := range pm.pollers {
+		pm.removePoller(poller)
+	}
+	pm.mu.Unlock()
 
-	for appID, poller := range pm.pollers {
-		pm.stopPoller(poller)
-		delete(pm.pollers, appID)
+	pm.workers.Wait()
+}
+
+// removePoller unregisters a poller and drops it from the queue. A poll that
+// is already running finishes but is not rescheduled; an on-demand poll still
+// waiting for a worker is released without a run. Callers must hold pm.mu.
+func (pm *PollingManager) removePoller(poller *AppPoller) {
+	poller.stopped = true
+	delete(pm.pollers, poller.appID)
+
+	pm.dequeue(poller)
+	if call, ok := pm.inFlight[poller.appID]; ok && !call.started {
+		delete(pm.inFlight, poller.appID)
+		close(call.done)
 	}
 }
 
-func (pm *PollingManager) stopPoller(poller *AppPoller) {
-	poller.ticker.Stop()
-	close(poller.done)
+// dequeue drops a poller from the queue if it is waiting there. Callers must hold pm.mu.
+func (pm *PollingManager) dequeue(poller *AppPoller) {
+	for i, queued := range pm.queue {
+		if queued == poller {
+			pm.queue = append(pm.queue[:i], pm.queue[i+1:]...)
+			return
+		}
+	}
 }
 
-func (pm *PollingManager) pollApp(poller *AppPoller) {
+// PollNow polls an app as soon as a worker is free, ahead of any scheduled
+// polls waiting in the queue. If the app is already being polled the
+// in-flight call is returned and started is false.
+func (pm *PollingManager) PollNow(appID string) (call *PollCall, started bool, err error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
 
-	pm.fetchAndStore(poller.appID, poller.interval)
+	if pm.ctx.Err() != nil {
+		return nil, false, ErrShuttingDown
+	}
 
-	for {
-		select {
-		case <-poller.ticker.C:
-			pm.fetchAndStore(poller.appID, poller.interval)
-		case <-poller.done:
-			return
-		case <-pm.ctx.Done():
-			return
+	poller, exists := pm.pollers[appID]
+	if !exists {
+		return nil, false, ErrAppNotPolled
+	}
+
+	if call, busy := pm.inFlight[appID]; busy {
+		return call, false, nil
+	}
+
+	call = pm.registerPoll(poller, true)
+	pm.dequeue(poller)
+	poller.state = pollerQueued
+	pm.queue = append([]*AppPoller{poller}, pm.queue...)
+	pm.signal()
+
+	pm.logger.Info("Queued on-demand poll", "app_id", appID)
+	return call, true, nil
+}
+
+// registerPoll records a poll so concurrent requests for the same app join
+// it. Manual polls bypass the circuit breaker. Callers must hold pm.mu.
+func (pm *PollingManager) registerPoll(poller *AppPoller, manual bool) *PollCall {
+	call := &PollCall{done: make(chan struct{}), manual: manual}
+	pm.inFlight[poller.appID] = call
+	return call
+}
+
+// execute runs a poll registered by registerPoll and schedules the poller's next
+// run one (effective) interval after this one started.
+func (pm *PollingManager) execute(poller *AppPoller, call *PollCall) {
+	started := time.Now()
+	run := pm.fetchAndStore(poller, call.manual)
+	if run == nil {
+		// Only an open circuit skips a poll; callers that joined it see why
+		poller.mu.Lock()
+		snapshot := *poller.circuit
+		poller.mu.Unlock()
+		call.skipped = &snapshot
+	}
+
+	pm.mu.Lock()
+	if pm.inFlight[poller.appID] == call {
+		delete(pm.inFlight, poller.appID)
+	}
+	if poller.adaptive {
+		effective := pm.adaptive.next(poller.effective, run)
+		if effective != poller.effective {
+			pm.logger.Info("Adjusted polling interval", "app_id", poller.appID, "from", poller.effective, "to", effective, "stored", run.Stored)
+			poller.effective = effective
 		}
 	}
+	poller.state = pollerIdle
+	poller.nextRun = started.Add(poller.effective)
+	pm.mu.Unlock()
+
+	call.run = run
+	close(call.done)
+
+	pm.signal()
 }
 
-func (pm *PollingManager) fetchAndStore(appID string, interval time.Duration) {
+// fetchAndStore polls an app once and records the outcome in poll_runs. It
+// returns nil without fetching when the app's circuit is open, unless manual.
+func (pm *PollingManager) fetchAndStore(poller *AppPoller, manual bool) *models.PollRun {
+	appID := poller.appID
+	if !manual && !pm.allowPoll(poller) {
+		pm.logger.Info("Skipping poll, circuit open", "app_id", appID)
+		return nil
+	}
+
+	run := &models.PollRun{AppID: appID, StartedAt: time.Now()}
+	var fetchErr error
+	defer func() { pm.recordRun(poller, run, fetchErr) }()
+
 	ctx, cancel := context.WithTimeout(pm.ctx, 2*time.Minute)
 	defer cancel()
 
-	reviews, err := pm.rssService.FetchWithRetry(ctx, appID, 3)
+	sourceName, source := pm.sourceFor(poller)
+	if source == nil {
+		fetchErr = fmt.Errorf("%w: %q", ErrNoSource, sourceName)
+		pm.logger.Error("Cannot poll app", "app_id", appID, "error", fetchErr)
+		message := fetchErr.Error()
+		run.Error = &message
+		return run
+	}
+
+	result, err := source.FetchWithRetry(ctx, appID, FetchOptions{
+		Countries:  poller.countries,
+		Format:     poller.format,
+		Known:      pm.repo.StoredReviewIDs,
+		Validators: pm.loadValidators(appID),
+	}, 3)
+	run.Attempts = result.Attempts
+	run.HTTPStatus = result.StatusCode
+	run.Pages = result.Pages
+	// A source that fails part way still returns what it fetched before
+	// then, which is stored below alongside the error
 	if err != nil {
-		pm.logger.Error("Failed to fetch reviews", "app_id", appID, "error", err)
-		return
+		fetchErr = err
+		switch {
+		case errors.Is(err, ErrAppNotFound):
+			pm.logger.Warn("App not found in any storefront, it may have been delisted", "app_id", appID, "error", err)
+		case errors.Is(err, ErrBudgetExhausted):
+			pm.logger.Warn("Outbound request budget exhausted, will retry next interval", "app_id", appID)
+		case errors.Is(err, ErrThrottled):
+			pm.logger.Warn("Feed throttled, will retry next interval", "app_id", appID, "error", err)
+		default:
+			pm.logger.Error("Failed to fetch reviews", "app_id", appID, "error", err)
+		}
+		message := err.Error()
+		run.Error = &message
+	}
+	// Storefronts that failed while others were fetched don't fail the run,
+	// so they neither trip the breaker nor hold back last_poll
+	if result.StorefrontErrors != nil {
+		pm.logger.Warn("Failed to fetch some storefronts", "app_id", appID, "error", result.StorefrontErrors)
+		message := result.StorefrontErrors.Error()
+		run.StorefrontErrors = &message
 	}
 
-	stored := 0
-	for _, review := range reviews {
-		// Check if review already exists
-		exists, err := pm.repo.ReviewExists(review.ID)
-		if err != nil {
-			pm.logger.Error("Failed to check review existence", "review_id", review.ID, "error", err)
-			continue
+	if result.Metadata != nil {
+		if err := pm.repo.UpsertAppMetadata(result.Metadata); err != nil {
+			pm.logger.Error("Failed to store app metadata", "app_id", appID, "error", err)
 		}
+	}
 
-		if !exists {
-			if err := pm.repo.CreateReview(&review); err != nil {
-				pm.logger.Error("Failed to store review", "review_id", review.ID, "error", err)
-				continue
+	failed := 0
+	if err := pm.storeReviews(ctx, appID, sourceName, result, fetchErr == nil, run); err != nil {
+		pm.logger.Error("Failed to store reviews", "app_id", appID, "error", err)
+		failed += len(result.Reviews)
+		fetchErr = errors.Join(fetchErr, fmt.Errorf("failed to store reviews: %w", err))
+		message := fetchErr.Error()
+		run.Error = &message
+	}
+	run.Fetched = len(result.Reviews)
+
+	for _, entry := range result.Quarantined {
+		if err := pm.repo.QuarantineEntry(&entry); err != nil {
+			pm.logger.Error("Failed to quarantine entry", "app_id", appID, "entry_id", entry.EntryID, "error", err)
+			failed++
+		}
+	}
+
+	// Keeping the old validators after a failed write makes the next poll
+	// download the feed again instead of getting a 304. Storefronts that
+	// failed to fetch have already dropped theirs.
+	if failed == 0 {
+		pm.saveValidators(appID, result.Validators)
+	}
+
+	pm.logger.Info("Polling completed", "app_id", appID, "countries", poller.countries, "pages", result.Pages, "not_modified", result.NotModified, "fetched", run.Fetched, "stored", run.Stored, "updated", run.Updated, "quarantined", len(result.Quarantined))
+	return run
+}
+
+// storeReviews writes a poll's reviews, the replies the source reported and,
+// when the fetch completed, the app's last poll time in one transaction, so
+// a failed write stores none of them. The scheduler keeps its own next run in
+// memory; last_poll only decides when the app is due after a restart. New
+// reviews are inserted in a batch; stored ones that changed go through
+// SaveReview so edits are recorded as revisions, and the rest are skipped.
+// Replies the source no longer reports are cleared.
+func (pm *PollingManager) storeReviews(ctx context.Context, appID, source string, result *FetchResult, complete bool, run *models.PollRun) error {
+	reviews := result.Reviews
+
+	tx, err := pm.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ids := make([]string, len(reviews))
+	for i, review := range reviews {
+		ids[i] = review.ID
+	}
+	stored, err := tx.StoredReviews(ids)
+	if err != nil {
+		return err
+	}
+
+	var fresh []models.Review
+	var replied []*models.Review
+	updated := 0
+	for i := range reviews {
+		current, ok := stored[reviews[i].ID]
+		if !ok {
+			fresh = append(fresh, reviews[i])
+			continue
+		}
+		if result.ReportsResponses && reviews[i].DeveloperResponse == nil && (current.DeveloperResponse != nil || current.Responded) {
+			cleared, err := tx.ClearResponse(reviews[i].ID, source)
+			if err != nil {
+				return fmt.Errorf("review %s: %w", reviews[i].ID, err)
+			}
+			if cleared {
+				pm.logger.Info("Cleared deleted developer response", "app_id", appID, "review_id", reviews[i].ID)
 			}
-			stored++
+		}
+
+		// A reply is recorded when the review changed, and when it was
+		// stored without one being tracked
+		needsSave := repository.NeedsSave(&current, &reviews[i])
+		if reviews[i].DeveloperResponse != nil && (needsSave || !current.Responded) {
+			replied = append(replied, &reviews[i])
+		}
+		if !needsSave {
+			continue
+		}
+		change, err := tx.SaveReview(&reviews[i])
+		if err != nil {
+			return fmt.Errorf("review %s: %w", reviews[i].ID, err)
+		}
+		if change == repository.ReviewUpdated {
+			updated++
 		}
 	}
 
-	// Update last poll time
-	now := time.Now()
-	config := &models.AppConfig{
-		AppID:    appID,
-		LastPoll: &now,
-		PollInterval: interval,
-		IsActive: true,
+	inserted, err := tx.CreateReviews(ctx, fresh)
+	if err != nil {
+		return err
+	}
+	for i := range fresh {
+		if fresh[i].DeveloperResponse != nil {
+			replied = append(replied, &fresh[i])
+		}
+	}
+	for _, review := range replied {
+		if err := tx.SaveResponse(syncedResponse(review, source)); err != nil {
+			return fmt.Errorf("response to review %s: %w", review.ID, err)
+		}
 	}
 
-	if err := pm.repo.UpsertAppConfig(config); err != nil {
-		pm.logger.Error("Failed to update app config", "app_id", appID, "error", err)
+	if complete {
+		if err := tx.UpdateLastPoll(appID, time.Now()); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	run.Stored = inserted
+	run.Updated = updated
+	return nil
+}
+
+// syncedResponse is the tracked response for a reply a source reported, so
+// the review counts as answered alongside replies entered through the API.
+func syncedResponse(review *models.Review, source string) *models.ReviewResponse {
+	respondedAt := time.Now()
+	if review.DeveloperResponseDate != nil {
+		respondedAt = *review.DeveloperResponseDate
 	}
 
-	pm.logger.Info("Polling completed", "app_id", appID, "fetched", len(reviews), "stored", stored)
+	return &models.ReviewResponse{
+		ReviewID:    review.ID,
+		AppID:       review.AppID,
+		Body:        *review.DeveloperResponse,
+		Source:      source,
+		RespondedAt: respondedAt,
+		UpdatedAt:   respondedAt,
+	}
 }
 
-func (pm *PollingManager) GetPollingStatus() map[string]interface{} {
+func (pm *PollingManager) recordRun(poller *AppPoller, run *models.PollRun, fetchErr error) {
+	run.DurationMs = time.Since(run.StartedAt).Milliseconds()
+
+	poller.mu.Lock()
+	poller.stats.record(run)
+	// Failures caused by our own shutdown, request budget or configuration
+	// say nothing about the feed
+	var circuit *models.CircuitBreaker
+	ours := pm.ctx.Err() != nil || errors.Is(fetchErr, ErrBudgetExhausted) || errors.Is(fetchErr, ErrNoSource)
+	if !ours && pm.breaker.record(poller.circuit, run.Error != nil, time.Now()) {
+		snapshot := *poller.circuit
+		circuit = &snapshot
+	}
+	poller.mu.Unlock()
+
+	if circuit != nil {
+		if circuit.State == models.CircuitOpen {
+			pm.logger.Warn("Circuit opened, pausing polls", "app_id", run.AppID, "failures", circuit.Failures, "cooldown", pm.breaker.cooldown)
+		}
+		pm.saveCircuit(circuit)
+	}
+
+	if err := pm.repo.CreatePollRun(run); err != nil {
+		pm.logger.Error("Failed to record poll run", "app_id", run.AppID, "error", err)
+	}
+}
+
+// loadValidators returns the feed validators stored for an app, keyed by
+// country. Polls without them just fetch the full feed.
+func (pm *PollingManager) loadValidators(appID string) map[string]FeedValidator {
+	entries, err := pm.repo.GetFeedCache(appID)
+	if err != nil {
+		pm.logger.Error("Failed to load feed cache", "app_id", appID, "error", err)
+		return nil
+	}
+
+	validators := make(map[string]FeedValidator, len(entries))
+	for _, entry := range entries {
+		validators[entry.Country] = FeedValidator{ETag: entry.ETag, LastModified: entry.LastModified}
+	}
+	return validators
+}
+
+func (pm *PollingManager) saveValidators(appID string, validators map[string]FeedValidator) {
+	for country, validator := range validators {
+		entry := &models.FeedCache{
+			AppID:        appID,
+			Country:      country,
+			ETag:         validator.ETag,
+			LastModified: validator.LastModified,
+			UpdatedAt:    time.Now(),
+		}
+		if err := pm.repo.UpsertFeedCache(entry); err != nil {
+			pm.logger.Error("Failed to store feed cache", "app_id", appID, "country", country, "error", err)
+		}
+	}
+}
+
+// allowPoll consults the app's circuit breaker before a scheduled poll.
+func (pm *PollingManager) allowPoll(poller *AppPoller) bool {
+	poller.mu.Lock()
+	allowed, changed := pm.breaker.allow(poller.circuit, time.Now())
+	snapshot := *poller.circuit
+	poller.mu.Unlock()
+
+	if changed {
+		pm.logger.Info("Circuit half-open, trying a poll", "app_id", poller.appID)
+		pm.saveCircuit(&snapshot)
+	}
+	return allowed
+}
+
+// loadCircuit restores an app's persisted breaker, starting closed if none is stored.
+func (pm *PollingManager) loadCircuit(appID string) *models.CircuitBreaker {
+	circuit, err := pm.repo.GetCircuitBreaker(appID)
+	if err != nil {
+		pm.logger.Warn("Failed to load circuit breaker", "app_id", appID, "error", err)
+	}
+	if circuit == nil {
+		return newCircuitBreaker(appID)
+	}
+	return circuit
+}
+
+func (pm *PollingManager) saveCircuit(circuit *models.CircuitBreaker) {
+	if err := pm.repo.UpsertCircuitBreaker(circuit); err != nil {
+		pm.logger.Error("Failed to save circuit breaker", "app_id", circuit.AppID, "error", err)
+	}
+}
+
+func (s *pollerStats) record(run *models.PollRun) {
+	startedAt := run.StartedAt
+	s.lastAttempt = &startedAt
+	s.lastPages = run.Pages
+
+	if run.Error != nil {
+		s.lastError = run.Error
+		s.consecutiveFailures++
+		return
+	}
+
+	s.lastSuccess = &startedAt
+	s.lastError = nil
+	s.consecutiveFailures = 0
+}
+
+// seedStats restores an app's poll history from poll_runs so a restart
+// doesn't reset its failure streak.
+func (pm *PollingManager) seedStats(config *models.AppConfig) pollerStats {
+	stats := pollerStats{lastSuccess: config.LastPoll}
+
+	runs, err := pm.repo.GetPollRuns(config.AppID, 100)
+	if err != nil {
+		pm.logger.Warn("Failed to load poll history", "app_id", config.AppID, "error", err)
+		return stats
+	}
+
+	// Runs are newest first; replay them oldest first.
+	for i := len(runs) - 1; i >= 0; i-- {
+		stats.record(&runs[i])
+	}
+	return stats
+}
+
+func (pm *PollingManager) GetPollingStatus() PollingStatus {
+	totals, err := pm.repo.CountReviewsByApp()
+	if err != nil {
+		pm.logger.Error("Failed to count reviews", "error", err)
+	}
+
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
-	status := make(map[string]interface{})
+	status := PollingStatus{
+		Workers:    pm.maxConcurrent,
+		Running:    pm.running,
+		QueueDepth: len(pm.queue),
+		Apps:       make(map[string]AppPollingStatus, len(pm.pollers)),
+	}
+
+	// Only Apple's feed is paced by the outbound budget
+	if budgeted, ok := pm.sources[models.SourceAppStoreRSS].(interface{ Metrics() OutboundMetrics }); ok {
+		status.Outbound = budgeted.Metrics()
+	}
+
 	for appID, poller := range pm.pollers {
-		status[appID] = map[string]interface{}{
-			"interval": poller.interval.String(),
-			"active":   true,
+		poller.mu.Lock()
+		stats := poller.stats
+		circuit := CircuitStatus{
+			State:    poller.circuit.State,
+			Failures: poller.circuit.Failures,
+			OpenedAt: poller.circuit.OpenedAt,
+			RetryAt:  pm.breaker.retryAt(poller.circuit),
+		}
+		poller.mu.Unlock()
+
+		status.Apps[appID] = AppPollingStatus{
+			Interval:            poller.interval.String(),
+			Adaptive:            poller.adaptive,
+			EffectiveInterval:   poller.effective.String(),
+			Platform:            poller.platform,
+			Source:              poller.source,
+			Countries:           poller.countries,
+			FeedFormat:          poller.format,
+			State:               poller.state.String(),
+			NextRun:             poller.nextRun,
+			LastAttempt:         stats.lastAttempt,
+			LastSuccess:         stats.lastSuccess,
+			LastError:           stats.lastError,
+			ConsecutiveFailures: stats.consecutiveFailures,
+			LastPages:           stats.lastPages,
+			TotalReviews:        totals[appID],
+			Circuit:             circuit,
+			Active:              true,
 		}
 	}
 
@@ -0,0 +1,821 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+	"github.com/youthtrouble/symmetrical-giggle/internal/repository"
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+func TestPollingManager_BoundedConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	polled := make(map[string]bool)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		polled[strings.TrimPrefix(strings.Split(r.URL.Path, "/")[5], "id=")] = true
+		mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		json.NewEncoder(w).Encode(models.RSSFeed{})
+	}))
+	defer server.Close()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	log := logger.New("error")
+	pm := NewPollingManager(repo, NewRSSServiceWithURL(log, server.URL), config.PollingConfig{MaxConcurrent: 2}, log)
+	defer pm.StopAll()
+
+	const apps = 6
+	for i := 0; i < apps; i++ {
+		pm.StartPolling(&models.AppConfig{AppID: fmt.Sprintf("%d", 1000+i), PollInterval: time.Hour})
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		done := len(polled) == apps
+		mu.Unlock()
+		if done || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(polled) != apps {
+		t.Fatalf("Expected %d apps polled, got %d", apps, len(polled))
+	}
+	if maxInFlight > 2 {
+		t.Errorf("Expected at most 2 concurrent fetches, got %d", maxInFlight)
+	}
+
+	status := pm.GetPollingStatus()
+	if status.Workers != 2 || len(status.Apps) != apps {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestPollingManager_ResumeFromLastPoll(t *testing.T) {
+	pm := NewPollingManager(nil, nil, config.PollingConfig{StartupJitter: 30 * time.Second}, logger.New("error"))
+
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		ts := now.Add(-d)
+		return &ts
+	}
+
+	tests := []struct {
+		name     string
+		lastPoll *time.Time
+		interval time.Duration
+		earliest time.Time
+		latest   time.Time
+	}{
+		{"keeps the regular slot", ago(10 * time.Minute), time.Hour, now.Add(50 * time.Minute), now.Add(50 * time.Minute)},
+		{"overdue app is jittered", ago(2 * time.Hour), time.Hour, now, now.Add(30 * time.Second)},
+		{"never polled app is jittered", nil, time.Hour, now, now.Add(30 * time.Second)},
+		{"jitter never exceeds the interval", nil, 10 * time.Second, now, now.Add(10 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := pm.resumeAt(&models.AppConfig{LastPoll: tt.lastPoll, PollInterval: tt.interval}, now)
+			if next.Before(tt.earliest) || next.After(tt.latest) {
+				t.Errorf("Expected next run between %s and %s, got %s", tt.earliest, tt.latest, next)
+			}
+		})
+	}
+}
+
+func TestPollingManager_RecordsPollRuns(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var feed models.RSSFeed
+		entry := models.RSSEntry{}
+		entry.ID.Label = "review-1"
+		entry.Rating.Label = "5"
+		entry.Updated.Label = time.Now().Format(time.RFC3339)
+		feed.Feed.Entry = []models.RSSEntry{entry}
+		json.NewEncoder(w).Encode(feed)
+	}))
+	defer server.Close()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	log := logger.New("error")
+	pm := NewPollingManager(repo, NewRSSServiceWithURL(log, server.URL), config.PollingConfig{}, log)
+
+	pm.fetchAndStore(&AppPoller{appID: "123456", interval: time.Hour, countries: []string{"us"}, circuit: newCircuitBreaker("123456")}, false)
+
+	runs, err := repo.GetPollRuns("123456", 10)
+	if err != nil {
+		t.Fatalf("Failed to get poll runs: %v", err)
+	}
+
+	if len(runs) != 1 {
+		t.Fatalf("Expected 1 poll run, got %d", len(runs))
+	}
+
+	run := runs[0]
+	if run.Error != nil {
+		t.Errorf("Expected no error, got %s", *run.Error)
+	}
+	if run.HTTPStatus != http.StatusOK || run.Attempts != 2 || run.Pages != 2 {
+		t.Errorf("Unexpected request counters: %+v", run)
+	}
+	if run.Fetched != 1 || run.Stored != 1 {
+		t.Errorf("Expected 1 fetched and 1 stored, got %d and %d", run.Fetched, run.Stored)
+	}
+}
+
+func TestPollingManager_StoresPartialFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		country := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")[0]
+		if country == "gb" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var feed models.RSSFeed
+		entry := models.RSSEntry{}
+		entry.ID.Label = "review-" + country
+		entry.Rating.Label = "5"
+		entry.Updated.Label = time.Now().Format(time.RFC3339)
+		feed.Feed.Entry = []models.RSSEntry{entry}
+		w.Header().Set("ETag", `"`+country+`"`)
+		json.NewEncoder(w).Encode(feed)
+	}))
+	defer server.Close()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	log := logger.New("error")
+	pm := NewPollingManager(repo, NewRSSServiceWithURL(log, server.URL), config.PollingConfig{}, log)
+
+	if err := repo.UpsertAppConfig(&models.AppConfig{AppID: "123456", IsActive: true}); err != nil {
+		t.Fatalf("Failed to create app config: %v", err)
+	}
+
+	poller := &AppPoller{appID: "123456", interval: time.Hour, countries: []string{"us", "gb", "de"}, circuit: newCircuitBreaker("123456")}
+	run := pm.fetchAndStore(poller, false)
+	if run.Error != nil {
+		t.Errorf("Expected the run to succeed with two storefronts fetched, got %s", *run.Error)
+	}
+	if run.StorefrontErrors == nil || !strings.Contains(*run.StorefrontErrors, "storefront gb") {
+		t.Errorf("Expected the gb storefront's error on the run, got %v", run.StorefrontErrors)
+	}
+	if poller.circuit.Failures != 0 {
+		t.Errorf("Expected the breaker not to count a failure, got %d", poller.circuit.Failures)
+	}
+	if app, err := repo.GetAppConfig("123456"); err != nil || app.LastPoll == nil {
+		t.Errorf("Expected last_poll to advance, got %+v (%v)", app, err)
+	}
+	if run.Fetched != 2 || run.Stored != 2 {
+		t.Errorf("Expected the us and de reviews stored, got %d fetched and %d stored", run.Fetched, run.Stored)
+	}
+	for _, id := range []string{"review-us", "review-de"} {
+		if exists, _ := repo.ReviewExists(id); !exists {
+			t.Errorf("Expected %s to be stored", id)
+		}
+	}
+
+	cache, err := repo.GetFeedCache("123456")
+	if err != nil {
+		t.Fatalf("Failed to get feed cache: %v", err)
+	}
+	for _, entry := range cache {
+		if entry.Country == "gb" {
+			t.Error("Expected no validator for the failed storefront")
+		}
+	}
+	if len(cache) != 2 {
+		t.Errorf("Expected validators for the 2 storefronts fetched, got %d", len(cache))
+	}
+}
+
+func TestPollingManager_FailsWhenEveryStorefrontFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	if err := repo.UpsertAppConfig(&models.AppConfig{AppID: "123456", IsActive: true}); err != nil {
+		t.Fatalf("Failed to create app config: %v", err)
+	}
+
+	log := logger.New("error")
+	pm := NewPollingManager(repo, NewRSSServiceWithURL(log, server.URL), config.PollingConfig{}, log)
+
+	poller := &AppPoller{appID: "123456", interval: time.Hour, countries: []string{"us", "gb"}, circuit: newCircuitBreaker("123456")}
+	run := pm.fetchAndStore(poller, false)
+	if run.Error == nil || !strings.Contains(*run.Error, "storefront us") || !strings.Contains(*run.Error, "storefront gb") {
+		t.Errorf("Expected both storefronts' errors on the run, got %v", run.Error)
+	}
+	if poller.circuit.Failures != 1 {
+		t.Errorf("Expected the breaker to count a failure, got %d", poller.circuit.Failures)
+	}
+	if app, err := repo.GetAppConfig("123456"); err != nil || app.LastPoll != nil {
+		t.Errorf("Expected last_poll not to advance, got %+v (%v)", app, err)
+	}
+}
+
+func TestPollingManager_QuarantinesBadEntries(t *testing.T) {
+	feed := `{"feed":{"entry":[
+		{"id":{"label":"good"},"im:rating":{"label":"5"},"updated":{"label":"2024-05-01T10:00:00-07:00"},"content":{"label":"Fine"}},
+		{"id":{"label":"bad"},"im:rating":{"label":"5"},"updated":{"label":"yesterday"},"content":{"label":"Odd date"}}
+	]}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	log := logger.New("error")
+	pm := NewPollingManager(repo, NewRSSServiceWithURL(log, server.URL), config.PollingConfig{}, log)
+	poller := &AppPoller{appID: "123456", interval: time.Hour, countries: []string{"us"}, circuit: newCircuitBreaker("123456")}
+
+	pm.fetchAndStore(poller, false)
+	pm.fetchAndStore(poller, false)
+
+	entries, err := repo.GetQuarantinedEntries(repository.QuarantineFilter{AppID: "123456"})
+	if err != nil {
+		t.Fatalf("Failed to get quarantined entries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 quarantined entry, got %d", len(entries))
+	}
+	if entries[0].EntryID != "bad" || !strings.Contains(entries[0].Reason, "date") || !strings.Contains(entries[0].Payload, `"yesterday"`) {
+		t.Errorf("Unexpected quarantined entry: %+v", entries[0])
+	}
+
+	// Nothing changes until the parser accepts the entry
+	result, err := ReprocessQuarantine(repo, log, "123456")
+	if err != nil {
+		t.Fatalf("Failed to reprocess: %v", err)
+	}
+	if result.Checked != 1 || result.Failed != 1 || result.Recovered != 0 {
+		t.Errorf("Expected the entry to fail again, got %+v", result)
+	}
+
+	// Stand in for a parser fix by repairing the stored payload
+	fixed := strings.Replace(entries[0].Payload, "yesterday", "2024-04-30T10:00:00-07:00", 1)
+	if err := repo.QuarantineEntry(&models.QuarantinedEntry{
+		AppID: "123456", Country: "us", EntryID: "bad", Reason: "test", Payload: fixed, Checksum: "fixed", QuarantinedAt: time.Now(),
+	}); err != nil {
+		t.Fatalf("Failed to quarantine entry: %v", err)
+	}
+
+	result, err = ReprocessQuarantine(repo, log, "123456")
+	if err != nil {
+		t.Fatalf("Failed to reprocess: %v", err)
+	}
+	if result.Checked != 2 || result.Recovered != 1 {
+		t.Errorf("Expected 1 of 2 entries recovered, got %+v", result)
+	}
+
+	if exists, _ := repo.ReviewExists("bad"); !exists {
+		t.Error("Expected the recovered review to be stored")
+	}
+}
+
+func TestPollingManager_PollNowDeduplicates(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		<-release
+		json.NewEncoder(w).Encode(models.RSSFeed{})
+	}))
+	defer server.Close()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	log := logger.New("error")
+	pm := NewPollingManager(repo, NewRSSServiceWithURL(log, server.URL), config.PollingConfig{MaxConcurrent: 1}, log)
+	defer pm.StopAll()
+
+	if _, _, err := pm.PollNow("123456"); err != ErrAppNotPolled {
+		t.Fatalf("Expected ErrAppNotPolled, got %v", err)
+	}
+
+	pm.startPoller(&models.AppConfig{AppID: "123456", PollInterval: time.Hour}, time.Now().Add(time.Hour))
+
+	first, started, err := pm.PollNow("123456")
+	if err != nil || !started {
+		t.Fatalf("Expected a new poll, got started=%v err=%v", started, err)
+	}
+
+	second, started, err := pm.PollNow("123456")
+	if err != nil || started || second != first {
+		t.Fatalf("Expected to join the in-flight poll, got started=%v err=%v", started, err)
+	}
+
+	if first.Run() != nil {
+		t.Error("Run should be nil while the poll is in flight")
+	}
+
+	close(release)
+
+	select {
+	case <-first.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Poll did not finish")
+	}
+
+	if run := first.Run(); run == nil || run.Error != nil {
+		t.Errorf("Expected a successful run, got %+v", run)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 1 {
+		t.Errorf("Expected a single feed request, got %d", requests)
+	}
+
+	if next := pm.GetPollingStatus().Apps["123456"].NextRun; time.Until(next) < 50*time.Minute {
+		t.Errorf("Expected the next run to move an interval out, got %s", next)
+	}
+}
+
+func TestPollingManager_PollNowWaitsForAWorker(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		order = append(order, strings.TrimPrefix(strings.Split(r.URL.Path, "/")[5], "id="))
+		mu.Unlock()
+		<-release
+		json.NewEncoder(w).Encode(models.RSSFeed{})
+	}))
+	defer server.Close()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	log := logger.New("error")
+	pm := NewPollingManager(repo, NewRSSServiceWithURL(log, server.URL), config.PollingConfig{MaxConcurrent: 1}, log)
+
+	// The only worker is busy with the first app while the second waits its turn
+	pm.startPoller(&models.AppConfig{AppID: "1001", PollInterval: time.Hour}, time.Now())
+	deadline := time.Now().Add(5 * time.Second)
+	for pm.GetPollingStatus().Running == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	pm.startPoller(&models.AppConfig{AppID: "1002", PollInterval: time.Hour}, time.Now())
+	pm.startPoller(&models.AppConfig{AppID: "1003", PollInterval: time.Hour}, time.Now().Add(time.Hour))
+	for pm.GetPollingStatus().QueueDepth == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	call, started, err := pm.PollNow("1003")
+	if err != nil || !started {
+		t.Fatalf("Expected a new poll, got started=%v err=%v", started, err)
+	}
+	if status := pm.GetPollingStatus(); status.Running != 1 || status.QueueDepth != 2 || status.Apps["1003"].State != "queued" {
+		t.Errorf("Expected the on-demand poll to wait for the worker, got %+v", status)
+	}
+
+	// Give the scheduler a moment to see the new head of the queue
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	select {
+	case <-call.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Poll did not finish")
+	}
+	if run := call.Run(); run == nil || run.Error != nil {
+		t.Errorf("Expected a successful run, got %+v", run)
+	}
+
+	mu.Lock()
+	if len(order) < 2 || order[1] != "1003" {
+		t.Errorf("Expected the on-demand poll to jump the queue, got %v", order)
+	}
+	mu.Unlock()
+
+	pm.StopAll()
+	if _, _, err := pm.PollNow("1003"); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Expected ErrShuttingDown after StopAll, got %v", err)
+	}
+}
+
+func TestPollingManager_PollNowReportsSkippedScheduledPoll(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(models.RSSFeed{})
+	}))
+	defer server.Close()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	log := logger.New("error")
+	cfg := config.PollingConfig{BreakerThreshold: 1, BreakerCooldown: time.Hour}
+	pm := NewPollingManager(repo, NewRSSServiceWithURL(log, server.URL), cfg, log)
+	defer pm.StopAll()
+
+	pm.startPoller(&models.AppConfig{AppID: "123456", PollInterval: time.Hour}, time.Now().Add(time.Hour))
+
+	// A scheduled poll of an app whose circuit is open has been picked up
+	opened := time.Now()
+	pm.mu.Lock()
+	poller := pm.pollers["123456"]
+	poller.circuit = &models.CircuitBreaker{AppID: "123456", State: models.CircuitOpen, Failures: 1, OpenedAt: &opened}
+	scheduled := pm.registerPoll(poller, false)
+	scheduled.started = true
+	pm.mu.Unlock()
+
+	call, started, err := pm.PollNow("123456")
+	if err != nil || started || call != scheduled {
+		t.Fatalf("Expected to join the scheduled poll, got started=%v err=%v", started, err)
+	}
+	if call.Skipped() != nil {
+		t.Error("Skipped should be nil while the poll is in flight")
+	}
+
+	pm.execute(poller, scheduled)
+
+	if run := call.Run(); run != nil {
+		t.Errorf("Expected no run for a skipped poll, got %+v", run)
+	}
+	if circuit := call.Skipped(); circuit == nil || circuit.State != models.CircuitOpen || circuit.Failures != 1 {
+		t.Errorf("Expected the open breaker state, got %+v", circuit)
+	}
+	if requests != 0 {
+		t.Errorf("Expected the skipped poll not to fetch, got %d requests", requests)
+	}
+}
+
+func TestPollingManager_StatusRestoresPollHistory(t *testing.T) {
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	failure := "RSS feed returned status 503"
+	base := time.Now().Add(-time.Hour)
+	for i, runErr := range []*string{nil, nil, &failure, &failure} {
+		run := &models.PollRun{AppID: "123456", StartedAt: base.Add(time.Duration(i) * time.Minute), Error: runErr}
+		if err := repo.CreatePollRun(run); err != nil {
+			t.Fatalf("Failed to create poll run: %v", err)
+		}
+	}
+
+	review := &models.Review{ID: "review-1", AppID: "123456", Author: "Test User", Rating: 5, SubmittedDate: time.Now(), CreatedAt: time.Now()}
+	if err := repo.CreateReview(review); err != nil {
+		t.Fatalf("Failed to create review: %v", err)
+	}
+
+	log := logger.New("error")
+	pm := NewPollingManager(repo, NewRSSService(log), config.PollingConfig{}, log)
+	defer pm.StopAll()
+
+	pm.startPoller(&models.AppConfig{AppID: "123456", PollInterval: time.Hour}, time.Now().Add(time.Hour))
+
+	status := pm.GetPollingStatus().Apps["123456"]
+	if status.ConsecutiveFailures != 2 {
+		t.Errorf("Expected 2 consecutive failures, got %d", status.ConsecutiveFailures)
+	}
+	if status.LastError == nil || *status.LastError != failure {
+		t.Errorf("Expected last error %q, got %v", failure, status.LastError)
+	}
+	if status.LastSuccess == nil || !status.LastSuccess.Equal(base.Add(time.Minute)) {
+		t.Errorf("Expected last success at %s, got %v", base.Add(time.Minute), status.LastSuccess)
+	}
+	if status.LastAttempt == nil || !status.LastAttempt.Equal(base.Add(3*time.Minute)) {
+		t.Errorf("Expected last attempt at %s, got %v", base.Add(3*time.Minute), status.LastAttempt)
+	}
+	if status.TotalReviews != 1 {
+		t.Errorf("Expected 1 review, got %d", status.TotalReviews)
+	}
+}
+
+func TestPollingManager_StoresPollInOneTransaction(t *testing.T) {
+	server, creds := newFakeAppStoreConnect(t, appStoreConnectPages)
+	defer server.Close()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	if err := repo.UpsertAppConfig(&models.AppConfig{AppID: "123456", PollInterval: time.Hour, IsActive: true}); err != nil {
+		t.Fatalf("Failed to create app config: %v", err)
+	}
+	// Stored before the reviewer changed their mind
+	edited := &models.Review{ID: "asc-2", AppID: "123456", Author: "Ben", Rating: 2, Content: "Meh", SubmittedDate: time.Now().Add(-48 * time.Hour), CreatedAt: time.Now()}
+	if err := repo.CreateReview(edited); err != nil {
+		t.Fatalf("Failed to create review: %v", err)
+	}
+
+	log := logger.New("error")
+	appStoreConnect, err := NewAppStoreConnectServiceWithURL(log, server.URL, creds)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	pm := NewPollingManager(repo, NewRSSServiceWithURL(log, server.URL+"/rss"), config.PollingConfig{}, log)
+	pm.AddSource(appStoreConnect)
+
+	poller := &AppPoller{appID: "123456", platform: models.PlatformIOS, source: models.SourceAppStoreConnect, interval: time.Hour, circuit: newCircuitBreaker("123456")}
+	run := pm.fetchAndStore(poller, true)
+	if run.Error != nil {
+		t.Fatalf("Expected no error, got %s", *run.Error)
+	}
+	if run.Stored != 2 || run.Updated != 1 {
+		t.Errorf("Expected 2 stored and 1 updated review, got %d and %d", run.Stored, run.Updated)
+	}
+
+	if revisions, err := repo.GetReviewRevisions("asc-2"); err != nil || len(revisions) != 1 {
+		t.Errorf("Expected the edit to be recorded, got %d revisions (%v)", len(revisions), err)
+	}
+
+	appConfig, err := repo.GetAppConfig("123456")
+	if err != nil {
+		t.Fatalf("Failed to get app config: %v", err)
+	}
+	if appConfig.LastPoll == nil {
+		t.Error("Expected the last poll time to be stored")
+	}
+}
+
+// failingTxRepository fails every transaction, standing in for a full disk.
+type failingTxRepository struct {
+	repository.Repository
+}
+
+func (r failingTxRepository) BeginTx(ctx context.Context) (repository.Tx, error) {
+	return nil, errors.New("disk full")
+}
+
+func TestPollingManager_RecordsStorageFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var feed models.RSSFeed
+		entry := models.RSSEntry{}
+		entry.ID.Label = "review-1"
+		entry.Rating.Label = "5"
+		entry.Updated.Label = time.Now().Format(time.RFC3339)
+		feed.Feed.Entry = []models.RSSEntry{entry}
+		json.NewEncoder(w).Encode(feed)
+	}))
+	defer server.Close()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	log := logger.New("error")
+	pm := NewPollingManager(failingTxRepository{repo}, NewRSSServiceWithURL(log, server.URL), config.PollingConfig{}, log)
+
+	run := pm.fetchAndStore(&AppPoller{appID: "123456", interval: time.Hour, countries: []string{"us"}, circuit: newCircuitBreaker("123456")}, false)
+	if run.Error == nil || !strings.Contains(*run.Error, "disk full") {
+		t.Errorf("Expected the storage failure on the run, got %v", run.Error)
+	}
+
+	runs, err := repo.GetPollRuns("123456", 10)
+	if err != nil || len(runs) != 1 || runs[0].Error == nil {
+		t.Errorf("Expected a failed poll run recorded, got %+v (%v)", runs, err)
+	}
+}
+
+// countingTxRepository counts the reviews its transactions save one by one.
+type countingTxRepository struct {
+	repository.Repository
+	saves *int
+}
+
+func (r countingTxRepository) BeginTx(ctx context.Context) (repository.Tx, error) {
+	tx, err := r.Repository.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return countingTx{Tx: tx, saves: r.saves}, nil
+}
+
+type countingTx struct {
+	repository.Tx
+	saves *int
+}
+
+func (t countingTx) SaveReview(review *models.Review) (repository.ReviewChange, error) {
+	*t.saves++
+	return t.Tx.SaveReview(review)
+}
+
+func TestPollingManager_SkipsUnchangedReviews(t *testing.T) {
+	votes := "1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var feed models.RSSFeed
+		entry := models.RSSEntry{}
+		entry.ID.Label = "review-1"
+		entry.Rating.Label = "5"
+		entry.Content.Label = "Great"
+		entry.VoteCount.Label = votes
+		entry.Updated.Label = "2024-05-01T10:00:00-07:00"
+		feed.Feed.Entry = []models.RSSEntry{entry}
+		json.NewEncoder(w).Encode(feed)
+	}))
+	defer server.Close()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	saves := 0
+	log := logger.New("error")
+	pm := NewPollingManager(countingTxRepository{Repository: repo, saves: &saves}, NewRSSServiceWithURL(log, server.URL), config.PollingConfig{}, log)
+	poller := &AppPoller{appID: "123456", interval: time.Hour, countries: []string{"us"}, circuit: newCircuitBreaker("123456")}
+
+	pm.fetchAndStore(poller, false)
+	pm.fetchAndStore(poller, false)
+	if saves != 0 {
+		t.Errorf("Expected an unchanged review not to be saved again, got %d saves", saves)
+	}
+
+	votes = "2"
+	pm.fetchAndStore(poller, false)
+	if saves != 1 {
+		t.Errorf("Expected the new vote count to be saved, got %d saves", saves)
+	}
+	if review, err := repo.GetReview("review-1"); err != nil || review.VoteCount != 2 {
+		t.Errorf("Expected 2 votes stored, got %+v (%v)", review, err)
+	}
+}
+
+func TestAdaptiveBounds_Next(t *testing.T) {
+	bounds := adaptiveBounds{min: time.Minute, max: time.Hour, highWater: 10}
+	failure := "timeout"
+
+	tests := []struct {
+		name    string
+		current time.Duration
+		run     *models.PollRun
+		want    time.Duration
+	}{
+		{"busy poll halves", 20 * time.Minute, &models.PollRun{Stored: 15}, 10 * time.Minute},
+		{"busy poll stops at min", 90 * time.Second, &models.PollRun{Stored: 50}, time.Minute},
+		{"empty poll backs off", 20 * time.Minute, &models.PollRun{}, 30 * time.Minute},
+		{"empty poll stops at max", 50 * time.Minute, &models.PollRun{}, time.Hour},
+		{"moderate poll holds", 20 * time.Minute, &models.PollRun{Stored: 3}, 20 * time.Minute},
+		{"failed poll holds", 20 * time.Minute, &models.PollRun{Error: &failure}, 20 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bounds.next(tt.current, tt.run); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBreakerSettings_Transitions(t *testing.T) {
+	settings := breakerSettings{threshold: 2, cooldown: time.Minute}
+	breaker := newCircuitBreaker("123456")
+	now := time.Now()
+
+	if changed := settings.record(breaker, true, now); !changed || breaker.State != models.CircuitClosed {
+		t.Fatalf("First failure should count but stay closed, got %+v", breaker)
+	}
+	settings.record(breaker, true, now)
+	if breaker.State != models.CircuitOpen {
+		t.Fatalf("Expected circuit to open at threshold, got %s", breaker.State)
+	}
+
+	if allowed, _ := settings.allow(breaker, now.Add(30*time.Second)); allowed {
+		t.Error("Open circuit should block polls during cooldown")
+	}
+
+	allowed, changed := settings.allow(breaker, now.Add(time.Minute))
+	if !allowed || !changed || breaker.State != models.CircuitHalfOpen {
+		t.Fatalf("Expected half-open trial after cooldown, got %+v", breaker)
+	}
+
+	settings.record(breaker, true, now.Add(time.Minute))
+	if breaker.State != models.CircuitOpen {
+		t.Fatalf("Failed trial should reopen the circuit, got %s", breaker.State)
+	}
+	if retry := settings.retryAt(breaker); retry == nil || !retry.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("Expected retry a cooldown after reopening, got %v", retry)
+	}
+
+	settings.allow(breaker, now.Add(2*time.Minute))
+	settings.record(breaker, false, now.Add(2*time.Minute))
+	if breaker.State != models.CircuitClosed || breaker.Failures != 0 {
+		t.Errorf("Successful trial should close the circuit, got %+v", breaker)
+	}
+}
+
+func TestPollingManager_CircuitBreakerSkipsAndPersists(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	log := logger.New("error")
+	cfg := config.PollingConfig{BreakerThreshold: 2, BreakerCooldown: time.Hour}
+	pm := NewPollingManager(repo, NewRSSServiceWithURL(log, server.URL), cfg, log)
+
+	poller := &AppPoller{appID: "123456", interval: time.Hour, circuit: pm.loadCircuit("123456")}
+	for i := 0; i < 3; i++ {
+		pm.fetchAndStore(poller, false)
+	}
+
+	mu.Lock()
+	if requests != 2 {
+		t.Errorf("Expected the open circuit to skip the third poll, got %d requests", requests)
+	}
+	mu.Unlock()
+
+	stored, err := repo.GetCircuitBreaker("123456")
+	if err != nil {
+		t.Fatalf("Failed to get circuit breaker: %v", err)
+	}
+	if stored == nil || stored.State != models.CircuitOpen || stored.Failures != 2 {
+		t.Fatalf("Expected a persisted open circuit, got %+v", stored)
+	}
+
+	if run := pm.fetchAndStore(poller, true); run == nil {
+		t.Error("Manual polls should bypass an open circuit")
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+	"github.com/youthtrouble/symmetrical-giggle/internal/repository"
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+// quarantine records a feed entry the parser rejected, keeping the payload as
+// served so nothing is lost to a parser bug.
+func quarantine(entry models.RSSEntry, appID, country string, reason error) models.QuarantinedEntry {
+	payload := entry.Raw
+	if len(payload) == 0 {
+		// Entries converted from the XML feed have no raw JSON, so keep the
+		// converted form, which reprocesses the same way
+		payload, _ = json.Marshal(entry)
+	}
+
+	return quarantinePayload(payload, appID, country, entry.ID.Label, reason)
+}
+
+// quarantinePayload records a rejected entry from any source.
+func quarantinePayload(payload []byte, appID, country, entryID string, reason error) models.QuarantinedEntry {
+	checksum := sha256.Sum256(payload)
+	return models.QuarantinedEntry{
+		AppID:         appID,
+		Country:       country,
+		EntryID:       entryID,
+		Reason:        reason.Error(),
+		Payload:       string(payload),
+		Checksum:      hex.EncodeToString(checksum[:]),
+		QuarantinedAt: time.Now(),
+	}
+}
+
+// ReprocessResult counts what ReprocessQuarantine did with the entries it checked.
+type ReprocessResult struct {
+	Checked   int `json:"checked"`
+	Recovered int `json:"recovered"`
+	Failed    int `json:"failed"`
+}
+
+// ReprocessQuarantine runs unresolved quarantined entries through the current
+// parser, for a single app or every app when appID is empty. Entries that now
+// parse are saved as reviews and marked resolved; the rest stay quarantined.
+func ReprocessQuarantine(repo repository.Repository, logger *logger.Logger, appID string) (*ReprocessResult, error) {
+	entries, err := repo.GetQuarantinedEntries(repository.QuarantineFilter{AppID: appID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quarantined entries: %w", err)
+	}
+
+	result := &ReprocessResult{}
+	for _, quarantined := range entries {
+		result.Checked++
+
+		review, err := reparse(quarantined)
+		if err != nil {
+			logger.Warn("Quarantined entry still fails to parse", "id", quarantined.ID, "error", err)
+			result.Failed++
+			continue
+		}
+
+		if _, err := repo.SaveReview(&review); err != nil {
+			return result, fmt.Errorf("failed to store review %s: %w", review.ID, err)
+		}
+		if err := repo.ResolveQuarantinedEntry(quarantined.ID, time.Now()); err != nil {
+			return result, fmt.Errorf("failed to resolve quarantined entry %d: %w", quarantined.ID, err)
+		}
+		result.Recovered++
+	}
+
+	return result, nil
+}
+
+// reparse runs a quarantined payload through the parser of the source that
+// served it: Google Play reviews carry a reviewId and App Store Connect
+// reviews a customerReviews type, feed entries neither.
+func reparse(quarantined models.QuarantinedEntry) (models.Review, error) {
+	payload := []byte(quarantined.Payload)
+	if customerReviewID(payload) != "" {
+		review, _, err := parseCustomerReview(payload, quarantined.AppID)
+		return review, err
+	}
+	if playReviewID(payload) != "" {
+		review, ok, err := parsePlayReview(payload, quarantined.AppID)
+		if err == nil && !ok {
+			err = errors.New("review has no user comment")
+		}
+		return review, err
+	}
+
+	var entry models.RSSEntry
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return models.Review{}, fmt.Errorf("failed to decode entry: %w", err)
+	}
+	return parseEntry(entry, quarantined.AppID, quarantined.Country)
+}
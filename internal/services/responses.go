@@ -0,0 +1,119 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+)
+
+const (
+	// ResponseSLA is how soon we commit to answering low-rated reviews.
+	ResponseSLA = 48 * time.Hour
+	// responseSLAMaxRating is the highest rating the SLA covers.
+	responseSLAMaxRating = 2
+)
+
+// responseBuckets groups ratings for the response report.
+var responseBuckets = []struct {
+	name     string
+	min, max int
+}{
+	{"1-2", 1, 2},
+	{"3", 3, 3},
+	{"4-5", 4, 5},
+}
+
+// ResponseBucketStats summarises how the reviews in one rating bucket were
+// answered.
+type ResponseBucketStats struct {
+	Bucket       string  `json:"bucket"`
+	Reviews      int     `json:"reviews"`
+	Responded    int     `json:"responded"`
+	ResponseRate float64 `json:"response_rate"`
+	// MedianResponseSeconds is nil until a review in the bucket is answered.
+	MedianResponseSeconds *int64 `json:"median_response_seconds"`
+}
+
+// ResponseSLAStats tracks the commitment to answer 1-2 star reviews within
+// ResponseSLA. Unanswered reviews still inside the window are pending and
+// don't count either way.
+type ResponseSLAStats struct {
+	Target   string `json:"target"`
+	Met      int    `json:"met"`
+	Breached int    `json:"breached"`
+	Pending  int    `json:"pending"`
+	// ComplianceRate is Met over Met plus Breached; zero when neither.
+	ComplianceRate float64 `json:"compliance_rate"`
+}
+
+// ResponseReport is an app's response rate and time to respond per rating
+// bucket, plus how it did against the SLA.
+type ResponseReport struct {
+	Buckets []ResponseBucketStats `json:"buckets"`
+	SLA     ResponseSLAStats      `json:"sla"`
+}
+
+// BuildResponseReport summarises response times as of now.
+func BuildResponseReport(times []models.ReviewResponseTime, now time.Time) ResponseReport {
+	report := ResponseReport{SLA: ResponseSLAStats{Target: ResponseSLA.String()}}
+
+	for _, bucket := range responseBuckets {
+		stats := ResponseBucketStats{Bucket: bucket.name}
+		var waits []time.Duration
+
+		for _, t := range times {
+			if t.Rating < bucket.min || t.Rating > bucket.max {
+				continue
+			}
+			stats.Reviews++
+			if t.RespondedAt == nil {
+				continue
+			}
+			stats.Responded++
+			// An edit moves the submitted date past our reply; count that as
+			// answered straight away
+			waits = append(waits, max(t.RespondedAt.Sub(t.SubmittedDate), 0))
+		}
+
+		if stats.Reviews > 0 {
+			stats.ResponseRate = float64(stats.Responded) / float64(stats.Reviews)
+		}
+		if len(waits) > 0 {
+			seconds := int64(median(waits).Seconds())
+			stats.MedianResponseSeconds = &seconds
+		}
+		report.Buckets = append(report.Buckets, stats)
+	}
+
+	for _, t := range times {
+		if t.Rating > responseSLAMaxRating {
+			continue
+		}
+		deadline := t.SubmittedDate.Add(ResponseSLA)
+		switch {
+		case t.RespondedAt != nil && !t.RespondedAt.After(deadline):
+			report.SLA.Met++
+		case t.RespondedAt != nil || now.After(deadline):
+			report.SLA.Breached++
+		default:
+			report.SLA.Pending++
+		}
+	}
+	if due := report.SLA.Met + report.SLA.Breached; due > 0 {
+		report.SLA.ComplianceRate = float64(report.SLA.Met) / float64(due)
+	}
+
+	return report
+}
+
+func median(durations []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
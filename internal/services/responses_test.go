@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+)
+
+func TestBuildResponseReport(t *testing.T) {
+	now := time.Now()
+	answered := func(rating int, age, wait time.Duration) models.ReviewResponseTime {
+		submitted := now.Add(-age)
+		respondedAt := submitted.Add(wait)
+		return models.ReviewResponseTime{Rating: rating, SubmittedDate: submitted, RespondedAt: &respondedAt}
+	}
+	unanswered := func(rating int, age time.Duration) models.ReviewResponseTime {
+		return models.ReviewResponseTime{Rating: rating, SubmittedDate: now.Add(-age)}
+	}
+
+	report := BuildResponseReport([]models.ReviewResponseTime{
+		answered(1, 96*time.Hour, 2*time.Hour),  // met
+		answered(2, 96*time.Hour, 10*time.Hour), // met
+		answered(1, 96*time.Hour, 60*time.Hour), // breached, answered late
+		unanswered(2, 72*time.Hour),             // breached, never answered
+		unanswered(1, time.Hour),                // pending
+		answered(3, 24*time.Hour, time.Hour),
+		unanswered(5, 24*time.Hour),
+	}, now)
+
+	low := report.Buckets[0]
+	if low.Bucket != "1-2" || low.Reviews != 5 || low.Responded != 3 {
+		t.Fatalf("Unexpected 1-2 bucket: %+v", low)
+	}
+	if low.ResponseRate != 0.6 {
+		t.Errorf("Expected a 0.6 response rate, got %f", low.ResponseRate)
+	}
+	if low.MedianResponseSeconds == nil || *low.MedianResponseSeconds != int64((10*time.Hour).Seconds()) {
+		t.Errorf("Expected a 10h median, got %v", low.MedianResponseSeconds)
+	}
+
+	if top := report.Buckets[2]; top.Bucket != "4-5" || top.Reviews != 1 || top.Responded != 0 || top.MedianResponseSeconds != nil {
+		t.Errorf("Unexpected 4-5 bucket: %+v", top)
+	}
+
+	sla := report.SLA
+	if sla.Met != 2 || sla.Breached != 2 || sla.Pending != 1 {
+		t.Errorf("Expected 2 met, 2 breached and 1 pending, got %+v", sla)
+	}
+	if sla.ComplianceRate != 0.5 || sla.Target != "48h0m0s" {
+		t.Errorf("Unexpected SLA summary: %+v", sla)
+	}
+}
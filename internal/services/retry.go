@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+var (
+	// ErrAppNotFound matches feed errors for apps the storefront doesn't
+	// list, typically because they were delisted or never sold there.
+	ErrAppNotFound = errors.New("app not found")
+	// ErrThrottled matches feed errors where Apple asked us to slow down.
+	ErrThrottled = errors.New("feed throttled")
+)
+
+// FeedError is returned when a review source answers with a non-200
+// status. Use errors.Is with ErrAppNotFound or ErrThrottled to classify it.
+type FeedError struct {
+	StatusCode int
+	// RetryAfter is the delay requested by the Retry-After header, if any.
+	RetryAfter time.Duration
+	// Source names the API that failed; the App Store RSS feed when empty.
+	Source string
+}
+
+func (e *FeedError) Error() string {
+	source := e.Source
+	if source == "" {
+		source = "RSS feed"
+	}
+	return fmt.Sprintf("%s returned status %d", source, e.StatusCode)
+}
+
+func (e *FeedError) Is(target error) bool {
+	switch target {
+	case ErrAppNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrThrottled:
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+	}
+	return false
+}
+
+// notSentError wraps a failure that happened before a request went out, such
+// as the outbound budget refusing it, so the try isn't counted as an attempt.
+type notSentError struct {
+	err error
+}
+
+func (e *notSentError) Error() string { return e.err.Error() }
+func (e *notSentError) Unwrap() error { return e.err }
+
+// notSent marks err as having stopped a request before it was sent.
+func notSent(err error) error {
+	return &notSentError{err: err}
+}
+
+// retryable reports whether a failed request is worth repeating. Client
+// errors other than timeouts and throttling won't change on a retry.
+func retryable(err error) bool {
+	if errors.Is(err, ErrBudgetExhausted) {
+		return false
+	}
+
+	var feedErr *FeedError
+	if !errors.As(err, &feedErr) {
+		// Transport and decode failures are usually transient
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	switch {
+	case feedErr.StatusCode == http.StatusRequestTimeout, feedErr.StatusCode == http.StatusTooManyRequests:
+		return true
+	case feedErr.StatusCode >= 500:
+		return true
+	}
+	return false
+}
+
+// retryDelay picks the wait before the next attempt: the server's
+// Retry-After when given, otherwise exponential backoff from base with equal
+// jitter, capped at max.
+func retryDelay(err error, attempt int, base, max time.Duration) time.Duration {
+	var feedErr *FeedError
+	if errors.As(err, &feedErr) && feedErr.RetryAfter > 0 {
+		return feedErr.RetryAfter
+	}
+
+	backoff := base << (attempt - 1)
+	if backoff <= 0 || backoff > max {
+		backoff = max
+	}
+
+	half := backoff / 2
+	return half + rand.N(half+1)
+}
+
+// retryRequest calls attempt until it succeeds, fails with an error that
+// isn't retryable, or has been tried maxRetries times, backing off between
+// tries. Every try that sent a request is counted in result along with the
+// last HTTP status seen.
+func retryRequest(ctx context.Context, logger *logger.Logger, result *FetchResult, maxRetries int, base, max time.Duration, attempt func() (int, error)) error {
+	var lastErr error
+
+	for try := 1; try <= maxRetries; try++ {
+		status, err := attempt()
+		var unsent *notSentError
+		if !errors.As(err, &unsent) {
+			result.Attempts++
+		}
+		if status != 0 {
+			result.StatusCode = status
+		}
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+		if !retryable(err) {
+			return err
+		}
+
+		if try < maxRetries {
+			backoff := retryDelay(err, try, base, max)
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+				// Waiting would outlive the poll; give up now and keep the error
+				return fmt.Errorf("retry in %s exceeds deadline: %w", backoff, err)
+			}
+			logger.Warn("Fetch failed, retrying", "attempt", try, "backoff", backoff, "error", err)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+	}
+
+	return fmt.Errorf("failed after %d attempts: %w", maxRetries, lastErr)
+}
+
+// parseRetryAfter reads a Retry-After header given either as delay seconds
+// or as an HTTP date.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(header); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
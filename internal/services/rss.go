@@ -3,7 +3,9 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -16,120 +18,446 @@ type RSSService struct {
 	client  *http.Client
 	logger  *logger.Logger
 	baseURL string
+	// Backoff between retries starts at retryBase and doubles up to retryMax.
+	retryBase time.Duration
+	retryMax  time.Duration
+	budget    *requestBudget
+	// archive, when set, receives every feed page body fetched.
+	archive *FeedArchive
 }
 
 func NewRSSService(logger *logger.Logger) *RSSService {
-	return &RSSService{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		logger:  logger,
-		baseURL: "https://itunes.apple.com/us/rss/customerreviews/id=%s/sortBy=mostRecent/json",
-	}
+	return NewRSSServiceWithURL(logger, "https://itunes.apple.com")
 }
 
-// NewRSSServiceWithURL creates a new RSS service with a custom base URL (useful for testing)
+// NewRSSServiceWithURL creates a new RSS service with a custom base URL (useful for testing).
+// The storefront and feed path are appended to baseURL.
 func NewRSSServiceWithURL(logger *logger.Logger, baseURL string) *RSSService {
 	return &RSSService{
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		logger:  logger,
-		baseURL: baseURL,
+		logger:    logger,
+		baseURL:   baseURL,
+		retryBase: time.Second,
+		retryMax:  30 * time.Second,
+		budget:    newRequestBudget(0, 0),
+	}
+}
+
+// SetRequestBudget caps the requests per minute this service makes across all
+// pollers, allowing bursts of up to burst requests. Zero removes the cap.
+func (s *RSSService) SetRequestBudget(perMinute, burst int) {
+	s.budget = newRequestBudget(perMinute, burst)
+}
+
+// SetArchive makes the service store every feed page it fetches in archive.
+// Nil stops archiving.
+func (s *RSSService) SetArchive(archive *FeedArchive) {
+	s.archive = archive
+}
+
+func (s *RSSService) Name() string {
+	return models.SourceAppStoreRSS
+}
+
+// Metrics reports usage of the outbound request budget.
+func (s *RSSService) Metrics() OutboundMetrics {
+	return s.budget.metrics()
+}
+
+// maxFeedPages is the number of pages Apple serves for the customer-reviews feed.
+const maxFeedPages = 10
+
+// FetchOptions controls which storefronts and how many pages FetchReviews walks.
+type FetchOptions struct {
+	Countries []string
+	// MaxPages caps the pages fetched per storefront. Zero means every page Apple serves.
+	MaxPages int
+	// Known reports which of a page's review IDs have already been stored,
+	// in one lookup. When set, a storefront stops paging after a page that
+	// contains only known reviews.
+	Known func(ids []string) (map[string]bool, error)
+	// Format selects the JSON or XML feed. JSON when empty.
+	Format string
+	// Validators holds the cache validators from each storefront's last
+	// successful fetch, keyed by country. Page 1 is requested conditionally
+	// and a 304 skips the storefront.
+	Validators map[string]FeedValidator
+}
+
+// FeedValidator is the ETag and Last-Modified Apple served for a feed.
+type FeedValidator struct {
+	ETag         string
+	LastModified string
+}
+
+// FetchResult holds the reviews gathered by a fetch and how many feed pages it
+// took. It is returned alongside errors too, so callers can record the
+// attempts made and the last HTTP status seen.
+type FetchResult struct {
+	Reviews    []models.Review
+	Pages      int
+	Attempts   int
+	StatusCode int
+	// NotModified counts storefronts that answered 304 to a conditional request.
+	NotModified int
+	// Validators holds the validators served with each storefront's first
+	// page, to be sent back on the next fetch.
+	Validators map[string]FeedValidator
+	// Metadata is the app listing from the first storefront that returned one.
+	Metadata *models.AppMetadata
+	// Quarantined holds entries the parser rejected.
+	Quarantined []models.QuarantinedEntry
+	// ReportsResponses is set by sources that report our replies, so a
+	// review returned without one has had its reply deleted.
+	ReportsResponses bool
+	// StorefrontErrors joins the errors of storefronts that failed while
+	// others were fetched, which doesn't fail the fetch as a whole.
+	StorefrontErrors error
+}
+
+// feedPage is a single decoded page of the feed.
+type feedPage struct {
+	reviews     []models.Review
+	metadata    *models.AppMetadata
+	quarantined []models.QuarantinedEntry
+	validator   FeedValidator
+	notModified bool
+}
+
+// FetchReviews walks the most recent pages of appID's feed in each configured
+// storefront, falling back to the default storefront when none are set.
+func (s *RSSService) FetchReviews(ctx context.Context, appID string, opts FetchOptions) (*FetchResult, error) {
+	return s.fetch(ctx, appID, opts, 1)
+}
+
+// FetchWithRetry behaves like FetchReviews but retries each failing page request.
+func (s *RSSService) FetchWithRetry(ctx context.Context, appID string, opts FetchOptions, maxRetries int) (*FetchResult, error) {
+	return s.fetch(ctx, appID, opts, maxRetries)
+}
+
+func (s *RSSService) fetch(ctx context.Context, appID string, opts FetchOptions, maxRetries int) (*FetchResult, error) {
+	countries := opts.Countries
+	if len(countries) == 0 {
+		countries = []string{models.DefaultCountry}
+	}
+
+	maxPages := opts.MaxPages
+	if maxPages <= 0 || maxPages > maxFeedPages {
+		maxPages = maxFeedPages
+	}
+
+	result := &FetchResult{Validators: make(map[string]FeedValidator)}
+	seen := make(map[string]bool)
+	var notFound error
+	var errs []error
+	succeeded := 0
+	for _, country := range countries {
+		err := s.fetchStorefront(ctx, result, seen, appID, country, opts, maxPages, maxRetries)
+		if errors.Is(err, ErrAppNotFound) {
+			// Not every app is sold in every storefront
+			s.logger.Warn("App not found in storefront", "app_id", appID, "country", country)
+			notFound = err
+			continue
+		}
+		if err != nil {
+			// Without its validator the next poll walks this storefront
+			// again instead of getting a 304 for a walk that never finished
+			delete(result.Validators, country)
+			errs = append(errs, fmt.Errorf("storefront %s: %w", country, err))
+
+			// The remaining storefronts would fail the same way
+			if ctx.Err() != nil || errors.Is(err, ErrBudgetExhausted) {
+				break
+			}
+			continue
+		}
+		succeeded++
+	}
+	if len(errs) > 0 {
+		if succeeded == 0 {
+			return result, errors.Join(errs...)
+		}
+		// The storefronts that were fetched are stored as usual
+		result.StorefrontErrors = errors.Join(errs...)
+	}
+
+	// Only an error when no storefront knew the app at all
+	if notFound != nil && result.Pages == 0 && result.NotModified == 0 {
+		return result, fmt.Errorf("app %s not found in any storefront: %w", appID, notFound)
+	}
+
+	return result, nil
+}
+
+func (s *RSSService) fetchStorefront(ctx context.Context, result *FetchResult, seen map[string]bool, appID, country string, opts FetchOptions, maxPages, maxRetries int) error {
+	for page := 1; page <= maxPages; page++ {
+		// Later pages only change when the first one does
+		var validator FeedValidator
+		if page == 1 {
+			validator = opts.Validators[country]
+		}
+
+		fetched, err := s.fetchPageWithRetry(ctx, result, appID, country, opts.Format, page, validator, maxRetries)
+		if err != nil {
+			if page > 1 && errors.Is(err, ErrAppNotFound) {
+				// Ran off the end of the feed
+				return nil
+			}
+			return fmt.Errorf("page %d: %w", page, err)
+		}
+		if fetched.notModified {
+			result.NotModified++
+			return nil
+		}
+		result.Pages++
+
+		if page == 1 && fetched.validator != (FeedValidator{}) {
+			result.Validators[country] = fetched.validator
+		}
+
+		if result.Metadata == nil {
+			result.Metadata = fetched.metadata
+		}
+		result.Quarantined = append(result.Quarantined, fetched.quarantined...)
+
+		// Past the last page the feed either comes back empty or repeats
+		// entries we already have, so a page with nothing new ends the walk.
+		var fresh []models.Review
+		for _, review := range fetched.reviews {
+			if !seen[review.ID] {
+				seen[review.ID] = true
+				fresh = append(fresh, review)
+			}
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+		result.Reviews = append(result.Reviews, fresh...)
+
+		if opts.Known != nil && allKnown(s.logger, fresh, opts.Known) {
+			return nil
+		}
 	}
+	return nil
 }
 
-func (s *RSSService) FetchReviews(ctx context.Context, appID string) ([]models.Review, error) {
-	url := fmt.Sprintf(s.baseURL, appID)
+// allKnown reports whether every review has already been stored, treating a
+// failed lookup as unknown.
+func allKnown(logger *logger.Logger, reviews []models.Review, known func(ids []string) (map[string]bool, error)) bool {
+	ids := make([]string, len(reviews))
+	for i, review := range reviews {
+		ids[i] = review.ID
+	}
+	stored, err := known(ids)
+	if err != nil {
+		logger.Warn("Failed to check review existence", "reviews", len(ids), "error", err)
+		return false
+	}
+	for _, id := range ids {
+		if !stored[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *RSSService) fetchPageWithRetry(ctx context.Context, result *FetchResult, appID, country, format string, page int, validator FeedValidator, maxRetries int) (*feedPage, error) {
+	var fetched *feedPage
+	err := retryRequest(ctx, s.logger, result, maxRetries, s.retryBase, s.retryMax, func() (int, error) {
+		var status int
+		var err error
+		fetched, status, err = s.fetchPage(ctx, appID, country, format, page, validator)
+		return status, err
+	})
+	return fetched, err
+}
+
+// fetchPage requests a single feed page, returning the HTTP status code
+// alongside the result whenever a response was received. A non-zero validator
+// makes the request conditional.
+func (s *RSSService) fetchPage(ctx context.Context, appID, country, format string, page int, validator FeedValidator) (*feedPage, int, error) {
+	if format == "" {
+		format = models.FeedFormatJSON
+	}
+	url := fmt.Sprintf("%s/%s/rss/customerreviews/page=%d/id=%s/sortBy=mostRecent/%s", s.baseURL, country, page, appID, format)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if validator.ETag != "" {
+		req.Header.Set("If-None-Match", validator.ETag)
+	}
+	if validator.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validator.LastModified)
+	}
+
+	if err := s.budget.wait(ctx); err != nil {
+		return nil, 0, notSent(err)
 	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch RSS feed: %w", err)
+		return nil, 0, fmt.Errorf("failed to fetch RSS feed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return &feedPage{notModified: true}, resp.StatusCode, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("RSS feed returned status %d", resp.StatusCode)
+		return nil, resp.StatusCode, &FeedError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 
-	var rssData models.RSSFeed
-	if err := json.NewDecoder(resp.Body).Decode(&rssData); err != nil {
-		return nil, fmt.Errorf("failed to decode RSS feed: %w", err)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("failed to read RSS feed: %w", err)
+	}
+	if s.archive != nil {
+		// A full disk shouldn't stop us collecting reviews
+		if err := s.archive.Write(appID, country, format, page, time.Now(), body); err != nil {
+			s.logger.Warn("Failed to archive feed page", "app_id", appID, "country", country, "page", page, "error", err)
+		}
 	}
 
-	return s.parseReviews(rssData, appID)
+	parsed, err := s.decodePage(body, format, appID, country)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	parsed.validator = FeedValidator{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	return parsed, resp.StatusCode, nil
+}
+
+// decodePage decodes and parses a feed page body in the given format.
+func (s *RSSService) decodePage(body []byte, format, appID, country string) (*feedPage, error) {
+	var rssData models.RSSFeed
+	switch format {
+	case models.FeedFormatXML:
+		feed, err := decodeAtomFeed(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode XML feed: %w", err)
+		}
+		rssData = feed
+	default:
+		if err := json.Unmarshal(body, &rssData); err != nil {
+			return nil, fmt.Errorf("failed to decode RSS feed: %w", err)
+		}
+	}
+	return s.parseReviews(rssData, appID, country)
 }
 
-func (s *RSSService) parseReviews(rssData models.RSSFeed, appID string) ([]models.Review, error) {
+func (s *RSSService) parseReviews(rssData models.RSSFeed, appID, country string) (*feedPage, error) {
+	page := &feedPage{}
 	var reviews []models.Review
 
 	for _, entry := range rssData.Feed.Entry {
-		// Skip the first entry as it's usually app metadata
-		if len(reviews) == 0 && entry.Rating.Label == "" {
+		// The first entry is usually app metadata rather than a review
+		if isMetadata(entry) {
+			if page.metadata == nil {
+				page.metadata = parseMetadata(entry, appID)
+			}
 			continue
 		}
 
-		rating, err := strconv.Atoi(entry.Rating.Label)
+		review, err := parseEntry(entry, appID, country)
 		if err != nil {
-			s.logger.Warn("Invalid rating format", "rating", entry.Rating.Label)
+			s.logger.Warn("Quarantining unparseable entry", "app_id", appID, "country", country, "entry_id", entry.ID.Label, "error", err)
+			page.quarantined = append(page.quarantined, quarantine(entry, appID, country, err))
 			continue
 		}
 
-		submittedDate, err := time.Parse(time.RFC3339, entry.Updated.Label)
-		if err != nil {
-			s.logger.Warn("Invalid date format", "date", entry.Updated.Label)
-			continue
-		}
+		reviews = append(reviews, review)
+	}
 
-		var title *string
-		if entry.Title.Label != "" {
-			title = &entry.Title.Label
-		}
+	page.reviews = reviews
+	return page, nil
+}
 
-		review := models.Review{
-			ID:            entry.ID.Label,
-			AppID:         appID,
-			Author:        entry.Author.Name.Label,
-			Rating:        rating,
-			Title:         title,
-			Content:       entry.Content.Label,
-			SubmittedDate: submittedDate,
-			CreatedAt:     time.Now(),
-		}
+// parseEntry converts a feed entry into a review, failing when the rating or
+// submission date can't be read.
+func parseEntry(entry models.RSSEntry, appID, country string) (models.Review, error) {
+	rating, err := strconv.Atoi(entry.Rating.Label)
+	if err != nil {
+		return models.Review{}, fmt.Errorf("invalid rating format %q", entry.Rating.Label)
+	}
 
-		reviews = append(reviews, review)
+	submittedDate, err := time.Parse(time.RFC3339, entry.Updated.Label)
+	if err != nil {
+		return models.Review{}, fmt.Errorf("invalid date format %q", entry.Updated.Label)
 	}
 
-	return reviews, nil
-}
+	var title *string
+	if entry.Title.Label != "" {
+		title = &entry.Title.Label
+	}
 
-func (s *RSSService) FetchWithRetry(ctx context.Context, appID string, maxRetries int) ([]models.Review, error) {
-	var lastErr error
+	return models.Review{
+		ID:            entry.ID.Label,
+		AppID:         appID,
+		Platform:      models.PlatformIOS,
+		Country:       country,
+		Author:        entry.Author.Name.Label,
+		Rating:        rating,
+		Title:         title,
+		Content:       entry.Content.Label,
+		AppVersion:    entry.Version.Label,
+		VoteSum:       parseCount(entry.VoteSum.Label),
+		VoteCount:     parseCount(entry.VoteCount.Label),
+		AuthorURI:     entry.Author.URI.Label,
+		ReviewURL:     entry.Link.Attributes.Href,
+		SubmittedDate: submittedDate,
+		CreatedAt:     time.Now(),
+	}, nil
+}
 
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		reviews, err := s.FetchReviews(ctx, appID)
-		if err == nil {
-			return reviews, nil
-		}
+// isMetadata reports whether an entry is the app listing Apple puts ahead of
+// the reviews: it has a name but no rating, author or content. Anything else
+// without a rating is a broken review and gets quarantined.
+func isMetadata(entry models.RSSEntry) bool {
+	return entry.Name.Label != "" && entry.Rating.Label == "" && entry.Author.Name.Label == "" && entry.Content.Label == ""
+}
 
-		lastErr = err
-		if attempt < maxRetries {
-			backoff := time.Duration(attempt) * time.Second
-			s.logger.Warn("RSS fetch failed, retrying", "attempt", attempt, "backoff", backoff, "error", err)
+func parseMetadata(entry models.RSSEntry, appID string) *models.AppMetadata {
+	metadata := &models.AppMetadata{
+		AppID:     appID,
+		Name:      entry.Name.Label,
+		Artist:    entry.Artist.Label,
+		BundleID:  entry.ID.Attributes.BundleID,
+		Category:  entry.Category.Attributes.Label,
+		StoreURL:  entry.Link.Attributes.Href,
+		UpdatedAt: time.Now(),
+	}
 
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(backoff):
-				continue
-			}
+	// Apple lists the icon at several sizes; keep the largest.
+	largest := -1
+	for _, image := range entry.Image {
+		height, err := strconv.Atoi(image.Attributes.Height)
+		if err != nil {
+			height = 0
+		}
+		if height > largest {
+			largest = height
+			metadata.IconURL = image.Label
 		}
 	}
 
-	return nil, fmt.Errorf("failed after %d attempts: %w", maxRetries, lastErr)
+	return metadata
+}
+
+// parseCount reads an optional vote counter, treating missing or malformed
+// values as zero rather than rejecting the review.
+func parseCount(label string) int {
+	n, err := strconv.Atoi(label)
+	if err != nil {
+		return 0
+	}
+	return n
 }
@@ -4,49 +4,35 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+	"github.com/youthtrouble/symmetrical-giggle/internal/repository"
 	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
 )
 
 func TestRSSService_FetchReviews(t *testing.T) {
 	// Mock RSS response
-	mockRSSData := models.RSSFeed{
-		Feed: struct {
-			Entry []models.RSSEntry `json:"entry"`
-		}{
-			Entry: []models.RSSEntry{
-				{
-					ID: struct {
-						Label string `json:"label"`
-					}{Label: "review-1"},
-					Author: struct {
-						Name struct {
-							Label string `json:"label"`
-						} `json:"name"`
-					}{Name: struct {
-						Label string `json:"label"`
-					}{Label: "Test User"}},
-					Rating: struct {
-						Label string `json:"label"`
-					}{Label: "5"},
-					Title: struct {
-						Label string `json:"label"`
-					}{Label: "Great App!"},
-					Content: struct {
-						Label string `json:"label"`
-					}{Label: "This app is amazing!"},
-					Updated: struct {
-						Label string `json:"label"`
-					}{Label: time.Now().Format(time.RFC3339)},
-				},
-			},
-		},
-	}
+	entry := models.RSSEntry{}
+	entry.ID.Label = "review-1"
+	entry.Author.Name.Label = "Test User"
+	entry.Rating.Label = "5"
+	entry.Title.Label = "Great App!"
+	entry.Content.Label = "This app is amazing!"
+	entry.Updated.Label = time.Now().Format(time.RFC3339)
+
+	var mockRSSData models.RSSFeed
+	mockRSSData.Feed.Entry = []models.RSSEntry{entry}
 
 	// Create test server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -56,16 +42,17 @@ func TestRSSService_FetchReviews(t *testing.T) {
 
 	// Override URL for testing
 	logger := logger.New("info")
-	service := NewRSSServiceWithURL(logger, server.URL+"/%s")
+	service := NewRSSServiceWithURL(logger, server.URL)
 
 	// Test with mock server
 	ctx := context.Background()
-	reviews, err := service.FetchReviews(ctx, "123456")
+	result, err := service.FetchReviews(ctx, "123456", FetchOptions{})
 
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 
+	reviews := result.Reviews
 	if len(reviews) != 1 {
 		t.Fatalf("Expected 1 review, got %d", len(reviews))
 	}
@@ -78,4 +65,697 @@ func TestRSSService_FetchReviews(t *testing.T) {
 	if review.Rating != 5 {
 		t.Errorf("Expected rating 5, got %d", review.Rating)
 	}
+
+	if review.Country != "us" {
+		t.Errorf("Expected country 'us', got '%s'", review.Country)
+	}
+}
+
+func TestRSSService_FetchReviewsMultipleStorefronts(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		country := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")[0]
+
+		var feed models.RSSFeed
+		entry := models.RSSEntry{}
+		entry.ID.Label = "review-" + country
+		entry.Rating.Label = "4"
+		entry.Updated.Label = time.Now().Format(time.RFC3339)
+		feed.Feed.Entry = []models.RSSEntry{entry}
+		json.NewEncoder(w).Encode(feed)
+	}))
+	defer server.Close()
+
+	service := NewRSSServiceWithURL(logger.New("error"), server.URL)
+
+	result, err := service.FetchReviews(context.Background(), "123456", FetchOptions{
+		Countries: []string{"us", "gb"},
+		MaxPages:  1,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	reviews := result.Reviews
+	if len(reviews) != 2 {
+		t.Fatalf("Expected 2 reviews, got %d", len(reviews))
+	}
+
+	for _, review := range reviews {
+		if review.ID != "review-"+review.Country {
+			t.Errorf("Review %s tagged with wrong country %s", review.ID, review.Country)
+		}
+	}
+
+	if paths[1] != "/gb/rss/customerreviews/page=1/id=123456/sortBy=mostRecent/json" {
+		t.Errorf("Unexpected storefront path %s", paths[1])
+	}
+}
+
+func TestRSSService_FetchReviewsPaging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var page int
+		fmt.Sscanf(strings.Split(r.URL.Path, "/")[4], "page=%d", &page)
+
+		var feed models.RSSFeed
+		if page <= 3 {
+			for i := 0; i < 2; i++ {
+				entry := models.RSSEntry{}
+				entry.ID.Label = fmt.Sprintf("review-%d-%d", page, i)
+				entry.Rating.Label = "3"
+				entry.Updated.Label = time.Now().Format(time.RFC3339)
+				feed.Feed.Entry = append(feed.Feed.Entry, entry)
+			}
+		}
+		json.NewEncoder(w).Encode(feed)
+	}))
+	defer server.Close()
+
+	service := NewRSSServiceWithURL(logger.New("error"), server.URL)
+
+	tests := []struct {
+		name        string
+		known       map[string]bool
+		wantPages   int
+		wantReviews int
+	}{
+		{"walks until an empty page", nil, 4, 6},
+		{"stops after a fully known page", map[string]bool{"review-2-0": true, "review-2-1": true}, 2, 4},
+		{"keeps going past a partially known page", map[string]bool{"review-1-0": true}, 4, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.FetchReviews(context.Background(), "123456", FetchOptions{
+				Known: func(ids []string) (map[string]bool, error) { return tt.known, nil },
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if result.Pages != tt.wantPages {
+				t.Errorf("Expected %d pages, got %d", tt.wantPages, result.Pages)
+			}
+			if len(result.Reviews) != tt.wantReviews {
+				t.Errorf("Expected %d reviews, got %d", tt.wantReviews, len(result.Reviews))
+			}
+		})
+	}
+}
+
+func TestRSSService_FetchReviewsFeedDetails(t *testing.T) {
+	feed := `{"feed":{"entry":[{
+		"author":{"uri":{"label":"https://itunes.apple.com/us/reviews/id1"},"name":{"label":"Jo"},"label":""},
+		"updated":{"label":"2024-05-01T10:00:00-07:00"},
+		"im:rating":{"label":"2"},
+		"im:version":{"label":"4.2.1"},
+		"id":{"label":"11223344"},
+		"title":{"label":"Crashes"},
+		"content":{"label":"Crashes on launch","attributes":{"type":"text"}},
+		"link":{"attributes":{"rel":"related","href":"https://itunes.apple.com/us/review?id=595068606&type=Purple%20Software"}},
+		"im:voteSum":{"label":"7"},
+		"im:contentType":{"attributes":{"term":"Application","label":"Application"}},
+		"im:voteCount":{"label":"9"}
+	}]}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	service := NewRSSServiceWithURL(logger.New("error"), server.URL)
+
+	result, err := service.FetchReviews(context.Background(), "595068606", FetchOptions{MaxPages: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(result.Reviews) != 1 {
+		t.Fatalf("Expected 1 review, got %d", len(result.Reviews))
+	}
+
+	review := result.Reviews[0]
+	if review.AppVersion != "4.2.1" {
+		t.Errorf("Expected version '4.2.1', got '%s'", review.AppVersion)
+	}
+	if review.VoteSum != 7 || review.VoteCount != 9 {
+		t.Errorf("Expected votes 7/9, got %d/%d", review.VoteSum, review.VoteCount)
+	}
+	if review.AuthorURI != "https://itunes.apple.com/us/reviews/id1" {
+		t.Errorf("Unexpected author URI '%s'", review.AuthorURI)
+	}
+	if review.ReviewURL != "https://itunes.apple.com/us/review?id=595068606&type=Purple%20Software" {
+		t.Errorf("Unexpected review URL '%s'", review.ReviewURL)
+	}
+}
+
+func TestRSSService_FetchReviewsMetadata(t *testing.T) {
+	feed := `{"feed":{"entry":[{
+		"im:name":{"label":"Example App"},
+		"im:image":[
+			{"label":"https://example.com/53x53.png","attributes":{"height":"53"}},
+			{"label":"https://example.com/100x100.png","attributes":{"height":"100"}},
+			{"label":"https://example.com/75x75.png","attributes":{"height":"75"}}
+		],
+		"im:artist":{"label":"Example Inc.","attributes":{"href":"https://apps.apple.com/us/developer/id1"}},
+		"title":{"label":"Example App - Example Inc."},
+		"link":{"attributes":{"rel":"alternate","type":"text/html","href":"https://apps.apple.com/us/app/id595068606"}},
+		"id":{"label":"https://apps.apple.com/us/app/id595068606","attributes":{"im:id":"595068606","im:bundleId":"com.example.app"}},
+		"category":{"attributes":{"im:id":"6005","term":"Social Networking","label":"Social Networking"}}
+	},{
+		"author":{"name":{"label":"Jo"}},
+		"updated":{"label":"2024-05-01T10:00:00-07:00"},
+		"im:rating":{"label":"5"},
+		"id":{"label":"11223344"},
+		"title":{"label":"Nice"},
+		"content":{"label":"Works well"}
+	}]}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	service := NewRSSServiceWithURL(logger.New("error"), server.URL)
+
+	result, err := service.FetchReviews(context.Background(), "595068606", FetchOptions{MaxPages: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(result.Reviews) != 1 {
+		t.Fatalf("Expected 1 review, got %d", len(result.Reviews))
+	}
+
+	metadata := result.Metadata
+	if metadata == nil {
+		t.Fatal("Expected app metadata")
+	}
+	if metadata.Name != "Example App" || metadata.Artist != "Example Inc." {
+		t.Errorf("Unexpected name/artist: %s / %s", metadata.Name, metadata.Artist)
+	}
+	if metadata.IconURL != "https://example.com/100x100.png" {
+		t.Errorf("Expected largest icon, got %s", metadata.IconURL)
+	}
+	if metadata.Category != "Social Networking" || metadata.BundleID != "com.example.app" {
+		t.Errorf("Unexpected category/bundle: %s / %s", metadata.Category, metadata.BundleID)
+	}
+}
+
+func TestRSSService_FetchWithRetryStatusHandling(t *testing.T) {
+	okFeed := func(w http.ResponseWriter) {
+		entry := models.RSSEntry{}
+		entry.ID.Label = "review-1"
+		entry.Rating.Label = "5"
+		entry.Updated.Label = time.Now().Format(time.RFC3339)
+		var feed models.RSSFeed
+		feed.Feed.Entry = []models.RSSEntry{entry}
+		json.NewEncoder(w).Encode(feed)
+	}
+
+	tests := []struct {
+		name         string
+		countries    []string
+		handler      func(w http.ResponseWriter, r *http.Request, attempt int)
+		wantFail     bool
+		wantErr      error
+		wantAttempts int
+	}{
+		{
+			name: "404 is not retried",
+			handler: func(w http.ResponseWriter, r *http.Request, attempt int) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantFail:     true,
+			wantErr:      ErrAppNotFound,
+			wantAttempts: 1,
+		},
+		{
+			name: "400 is not retried",
+			handler: func(w http.ResponseWriter, r *http.Request, attempt int) {
+				w.WriteHeader(http.StatusBadRequest)
+			},
+			wantFail:     true,
+			wantAttempts: 1,
+		},
+		{
+			name: "persistent 429 is reported as throttled",
+			handler: func(w http.ResponseWriter, r *http.Request, attempt int) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantFail:     true,
+			wantErr:      ErrThrottled,
+			wantAttempts: 3,
+		},
+		{
+			name: "503 with Retry-After recovers",
+			handler: func(w http.ResponseWriter, r *http.Request, attempt int) {
+				if attempt == 1 {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				okFeed(w)
+			},
+			wantAttempts: 3, // failed page 1, page 1 again, page 2 repeats the feed
+		},
+		{
+			name:      "missing storefront is skipped",
+			countries: []string{"zz", "us"},
+			handler: func(w http.ResponseWriter, r *http.Request, attempt int) {
+				if strings.HasPrefix(r.URL.Path, "/zz/") {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				okFeed(w)
+			},
+			wantAttempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				tt.handler(w, r, attempts)
+			}))
+			defer server.Close()
+
+			service := NewRSSServiceWithURL(logger.New("error"), server.URL)
+			service.retryBase = time.Millisecond
+
+			result, err := service.FetchWithRetry(context.Background(), "123456", FetchOptions{Countries: tt.countries}, 3)
+
+			if (err != nil) != tt.wantFail {
+				t.Errorf("Expected failure %v, got %v", tt.wantFail, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+
+			if result.Attempts != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.wantAttempts, result.Attempts)
+			}
+		})
+	}
+}
+
+func TestRSSService_FetchReviewsConditional(t *testing.T) {
+	const etag = `"feed-v1"`
+	const lastModified = "Wed, 01 May 2024 12:00:00 GMT"
+
+	var conditional []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			conditional = append(conditional, r.URL.Path)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		entry := models.RSSEntry{}
+		entry.ID.Label = "review-1"
+		entry.Rating.Label = "5"
+		entry.Updated.Label = time.Now().Format(time.RFC3339)
+		var feed models.RSSFeed
+		feed.Feed.Entry = []models.RSSEntry{entry}
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		json.NewEncoder(w).Encode(feed)
+	}))
+	defer server.Close()
+
+	service := NewRSSServiceWithURL(logger.New("error"), server.URL)
+	opts := FetchOptions{MaxPages: 1}
+
+	result, err := service.FetchReviews(context.Background(), "123456", opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(result.Reviews) != 1 {
+		t.Fatalf("Expected 1 review, got %d", len(result.Reviews))
+	}
+	want := FeedValidator{ETag: etag, LastModified: lastModified}
+	if result.Validators["us"] != want {
+		t.Errorf("Expected validator %+v, got %+v", want, result.Validators["us"])
+	}
+
+	// Sending the validators back gets a 304, which is an empty success
+	opts.Validators = result.Validators
+	result, err = service.FetchReviews(context.Background(), "123456", opts)
+	if err != nil {
+		t.Fatalf("Expected no error on 304, got %v", err)
+	}
+	if len(result.Reviews) != 0 || result.Pages != 0 || result.NotModified != 1 {
+		t.Errorf("Expected a not-modified storefront and no reviews, got %+v", result)
+	}
+	if len(conditional) != 1 {
+		t.Errorf("Expected 1 conditional request, got %d", len(conditional))
+	}
+}
+
+func TestRSSService_FeedFixtures(t *testing.T) {
+	tests := []struct {
+		fixture      string
+		wantIDs      []string
+		wantRatings  []int
+		wantMetadata bool
+		// wantQuarantined lists the IDs of entries the parser rejects.
+		wantQuarantined []string
+	}{
+		{fixture: "standard.json", wantIDs: []string{"1001", "1002"}, wantRatings: []int{5, 2}, wantMetadata: true},
+		{fixture: "single_review.json", wantIDs: []string{"1001"}, wantRatings: []int{4}},
+		{fixture: "metadata_only.json", wantMetadata: true},
+		{fixture: "no_entries.json"},
+		{fixture: "empty_feed.json"},
+		{fixture: "numeric_labels.json", wantIDs: []string{"1001"}, wantRatings: []int{3}},
+		{fixture: "missing_fields.json", wantIDs: []string{"1001", "1002"}, wantRatings: []int{1, 5}},
+		{fixture: "unrated_review.json", wantIDs: []string{"1001"}, wantRatings: []int{4}, wantQuarantined: []string{"1000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", "feeds", tt.fixture))
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(body)
+			}))
+			defer server.Close()
+
+			service := NewRSSServiceWithURL(logger.New("error"), server.URL)
+
+			result, err := service.FetchReviews(context.Background(), "595068606", FetchOptions{MaxPages: 1})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if len(result.Reviews) != len(tt.wantIDs) {
+				t.Fatalf("Expected %d reviews, got %d", len(tt.wantIDs), len(result.Reviews))
+			}
+			for i, review := range result.Reviews {
+				if review.ID != tt.wantIDs[i] || review.Rating != tt.wantRatings[i] {
+					t.Errorf("Expected review %s rated %d, got %s rated %d", tt.wantIDs[i], tt.wantRatings[i], review.ID, review.Rating)
+				}
+			}
+
+			if (result.Metadata != nil) != tt.wantMetadata {
+				t.Errorf("Expected metadata %v, got %+v", tt.wantMetadata, result.Metadata)
+			}
+
+			var quarantined []string
+			for _, entry := range result.Quarantined {
+				quarantined = append(quarantined, entry.EntryID)
+			}
+			if !reflect.DeepEqual(quarantined, tt.wantQuarantined) {
+				t.Errorf("Expected %v quarantined, got %v", tt.wantQuarantined, quarantined)
+			}
+		})
+	}
+}
+
+func TestRSSService_FetchReviewsXML(t *testing.T) {
+	fixtures := map[string][]byte{}
+	for _, format := range []string{models.FeedFormatJSON, models.FeedFormatXML} {
+		body, err := os.ReadFile(filepath.Join("testdata", "feeds", "standard."+format))
+		if err != nil {
+			t.Fatalf("Failed to read fixture: %v", err)
+		}
+		fixtures[format] = body
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(fixtures[path.Base(r.URL.Path)])
+	}))
+	defer server.Close()
+
+	service := NewRSSServiceWithURL(logger.New("error"), server.URL)
+
+	results := map[string]*FetchResult{}
+	for format := range fixtures {
+		result, err := service.FetchReviews(context.Background(), "595068606", FetchOptions{Format: format, MaxPages: 1})
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", format, err)
+		}
+		for i := range result.Reviews {
+			result.Reviews[i].CreatedAt = time.Time{}
+		}
+		if result.Metadata != nil {
+			result.Metadata.UpdatedAt = time.Time{}
+		}
+		results[format] = result
+	}
+
+	jsonResult, xmlResult := results[models.FeedFormatJSON], results[models.FeedFormatXML]
+	if len(xmlResult.Reviews) != 2 {
+		t.Fatalf("Expected 2 reviews from the XML feed, got %d", len(xmlResult.Reviews))
+	}
+	if !reflect.DeepEqual(jsonResult.Reviews, xmlResult.Reviews) {
+		t.Errorf("XML reviews differ from JSON:\njson: %+v\nxml:  %+v", jsonResult.Reviews, xmlResult.Reviews)
+	}
+	if !reflect.DeepEqual(jsonResult.Metadata, xmlResult.Metadata) {
+		t.Errorf("XML metadata differs from JSON:\njson: %+v\nxml:  %+v", jsonResult.Metadata, xmlResult.Metadata)
+	}
+}
+
+func TestRSSFeed_LenientDecoding(t *testing.T) {
+	// Spot-check the fields the lenient decoder has to coerce
+	read := func(name string) *models.RSSFeed {
+		body, err := os.ReadFile(filepath.Join("testdata", "feeds", name))
+		if err != nil {
+			t.Fatalf("Failed to read fixture: %v", err)
+		}
+		var feed models.RSSFeed
+		if err := json.Unmarshal(body, &feed); err != nil {
+			t.Fatalf("Failed to decode %s: %v", name, err)
+		}
+		return &feed
+	}
+
+	numeric := read("numeric_labels.json").Feed.Entry[0]
+	if numeric.Version.Label != "4.2" || numeric.VoteSum.Label != "7" || numeric.ID.Label != "1001" {
+		t.Errorf("Unexpected numeric labels: version %q, votes %q, id %q", numeric.Version.Label, numeric.VoteSum.Label, numeric.ID.Label)
+	}
+
+	missing := read("missing_fields.json").Feed.Entry
+	if missing[0].Title.Label != "" || missing[0].Link.Attributes.Href != "" {
+		t.Errorf("Expected empty title and link, got %q / %q", missing[0].Title.Label, missing[0].Link.Attributes.Href)
+	}
+	if missing[1].Content.Label != "Bare values instead of label objects" {
+		t.Errorf("Expected bare content value, got %q", missing[1].Content.Label)
+	}
+	if missing[1].Link.Attributes.Href == "" {
+		t.Error("Expected the link from a list of links")
+	}
+
+	metadata := read("metadata_only.json").Feed.Entry[0]
+	if len(metadata.Image) != 1 || metadata.ID.Attributes.BundleID != "com.example.app" {
+		t.Errorf("Unexpected metadata entry: %+v", metadata)
+	}
+}
+
+func TestRSSService_ArchiveAndReplay(t *testing.T) {
+	feed := `{"feed":{"entry":[
+		{"id":{"label":"stored"},"im:rating":{"label":"4"},"im:version":{"label":"2.0"},"updated":{"label":"2024-05-01T10:00:00-07:00"},"content":{"label":"Already stored"}},
+		{"id":{"label":"missed"},"im:rating":{"label":"3"},"im:version":{"label":"2.0"},"updated":{"label":"2024-05-01T09:00:00-07:00"},"content":{"label":"Never stored"}}
+	]}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	archive := NewFeedArchive(t.TempDir())
+	service := NewRSSServiceWithURL(logger.New("error"), server.URL)
+	service.SetArchive(archive)
+
+	if _, err := service.FetchReviews(context.Background(), "123456", FetchOptions{Countries: []string{"gb"}, MaxPages: 1}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	payloads, err := archive.List("123456")
+	if err != nil {
+		t.Fatalf("Failed to list archive: %v", err)
+	}
+	if len(payloads) != 1 {
+		t.Fatalf("Expected 1 archived page, got %d", len(payloads))
+	}
+	if payloads[0].Country != "gb" || payloads[0].Page != 1 {
+		t.Errorf("Unexpected archived page: %+v", payloads[0])
+	}
+	body, err := archive.Read(payloads[0])
+	if err != nil {
+		t.Fatalf("Failed to read archived page: %v", err)
+	}
+	if string(body) != feed {
+		t.Errorf("Archived body doesn't match the feed: %s", body)
+	}
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	defer repo.Close()
+
+	// Stored before versions were captured, and edited since the page was fetched
+	if err := repo.CreateReview(&models.Review{ID: "stored", AppID: "123456", Country: "gb", Rating: 5, Content: "Edited", SubmittedDate: time.Now()}); err != nil {
+		t.Fatalf("Failed to create review: %v", err)
+	}
+
+	result, err := ReplayArchive(repo, service, archive, "123456")
+	if err != nil {
+		t.Fatalf("Failed to replay archive: %v", err)
+	}
+	if result.Payloads != 1 || result.Inserted != 1 || result.Backfilled != 1 {
+		t.Errorf("Expected 1 insert and 1 backfill, got %+v", result)
+	}
+
+	stored, err := repo.GetReview("stored")
+	if err != nil {
+		t.Fatalf("Failed to get review: %v", err)
+	}
+	if stored.AppVersion != "2.0" || stored.Content != "Edited" || stored.Rating != 5 {
+		t.Errorf("Expected only the version to be backfilled, got %+v", stored)
+	}
+
+	// Replaying again has nothing left to do
+	result, err = ReplayArchive(repo, service, archive, "")
+	if err != nil {
+		t.Fatalf("Failed to replay archive: %v", err)
+	}
+	if result.Inserted != 0 || result.Backfilled != 0 {
+		t.Errorf("Expected a second replay to change nothing, got %+v", result)
+	}
+}
+
+func TestFeedArchive_WriteAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	archive := NewFeedArchive(dir)
+
+	for _, appID := range []string{"..", "../outside", `..\outside`, ""} {
+		if err := archive.Write(appID, "us", models.FeedFormatJSON, 1, time.Now(), []byte("{}")); err == nil {
+			t.Errorf("Expected app ID %q to be rejected", appID)
+		}
+	}
+	if err := archive.Write("123456", "../..", models.FeedFormatJSON, 1, time.Now(), []byte("{}")); err == nil {
+		t.Error("Expected a country that leaves the archive to be rejected")
+	}
+
+	now := time.Now()
+	for _, write := range []struct {
+		appID   string
+		fetched time.Time
+	}{
+		{"123456", now.Add(-48 * time.Hour)},
+		{"123456", now},
+		{"654321", now.Add(-72 * time.Hour)},
+	} {
+		if err := archive.Write(write.appID, "us", models.FeedFormatJSON, 1, write.fetched, []byte("{}")); err != nil {
+			t.Fatalf("Failed to archive page: %v", err)
+		}
+	}
+
+	pruned, err := archive.Prune(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to prune archive: %v", err)
+	}
+	if pruned != 2 {
+		t.Errorf("Expected 2 pages pruned, got %d", pruned)
+	}
+
+	payloads, err := archive.List("")
+	if err != nil {
+		t.Fatalf("Failed to list archive: %v", err)
+	}
+	if len(payloads) != 1 || payloads[0].AppID != "123456" {
+		t.Errorf("Expected only the recent page kept, got %+v", payloads)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "654321")); !os.IsNotExist(err) {
+		t.Errorf("Expected the emptied app directory to be removed, got %v", err)
+	}
+}
+
+func TestRSSService_RequestBudget(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		country := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")[0]
+
+		var feed models.RSSFeed
+		entry := models.RSSEntry{}
+		entry.ID.Label = "review-" + country
+		entry.Rating.Label = "4"
+		entry.Updated.Label = time.Now().Format(time.RFC3339)
+		feed.Feed.Entry = []models.RSSEntry{entry}
+		json.NewEncoder(w).Encode(feed)
+	}))
+	defer server.Close()
+
+	service := NewRSSServiceWithURL(logger.New("error"), server.URL)
+	opts := FetchOptions{Countries: []string{"us", "gb", "de"}, MaxPages: 1}
+
+	// 600/min refills a token every 100ms, so the burst covers two storefronts
+	// and the third has to wait
+	service.SetRequestBudget(600, 2)
+	if _, err := service.FetchReviews(context.Background(), "123456", opts); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	metrics := service.Metrics()
+	if metrics.Requests != 3 || metrics.Waits != 1 || metrics.Rejected != 0 {
+		t.Errorf("Expected 3 requests with 1 wait, got %+v", metrics)
+	}
+	if metrics.WaitTimeMs < 50 {
+		t.Errorf("Expected to wait for a token, waited %dms", metrics.WaitTimeMs)
+	}
+
+	// At 1/min the third storefront can't get a token before the deadline
+	requests = 0
+	service.SetRequestBudget(1, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The two storefronts fetched keep the fetch from failing
+	result, err := service.FetchWithRetry(ctx, "123456", opts, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !errors.Is(result.StorefrontErrors, ErrBudgetExhausted) {
+		t.Fatalf("Expected %v for the third storefront, got %v", ErrBudgetExhausted, result.StorefrontErrors)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests to reach the feed, got %d", requests)
+	}
+	if result.Attempts != 2 {
+		t.Errorf("Expected the rejected request not to count as an attempt, got %d attempts", result.Attempts)
+	}
+	if metrics := service.Metrics(); metrics.Rejected != 1 {
+		t.Errorf("Expected 1 rejected request, got %d", metrics.Rejected)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-5", 0},
+		{"Wed, 01 May 2024 12:00:30 GMT", 30 * time.Second},
+		{"Wed, 01 May 2024 11:00:00 GMT", 0},
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
 }
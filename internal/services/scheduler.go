@@ -0,0 +1,143 @@
+package services
+
+import (
+	"time"
+)
+
+// startWorkers launches the scheduler loop and a pool of maxConcurrent
+// workers. Due pollers are queued by the scheduler and handed to whichever
+// worker is free, so at most maxConcurrent fetches run at once.
+func (pm *PollingManager) startWorkers() {
+	pm.workers.Add(pm.maxConcurrent + 1)
+
+	go func() {
+		defer pm.workers.Done()
+		pm.schedule()
+	}()
+
+	for i := 0; i < pm.maxConcurrent; i++ {
+		go func() {
+			defer pm.workers.Done()
+			pm.work()
+		}()
+	}
+
+	pm.logger.Info("Started polling scheduler", "workers", pm.maxConcurrent)
+}
+
+// signal wakes the scheduler so it re-evaluates due times after a change.
+func (pm *PollingManager) signal() {
+	select {
+	case pm.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (pm *PollingManager) schedule() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		pm.mu.Lock()
+		next := pm.enqueueDue(time.Now())
+
+		var jobs chan *AppPoller
+		var head *AppPoller
+		if len(pm.queue) > 0 {
+			jobs = pm.jobs
+			head = pm.queue[0]
+		}
+		pm.mu.Unlock()
+
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		// jobs is nil while the queue is empty, which disables that case
+		select {
+		case jobs <- head:
+			pm.mu.Lock()
+			for i, queued := range pm.queue {
+				if queued == head {
+					pm.queue = append(pm.queue[:i], pm.queue[i+1:]...)
+					break
+				}
+			}
+			pm.mu.Unlock()
+		case <-timer.C:
+		case <-pm.wake:
+		case <-pm.ctx.Done():
+			return
+		}
+	}
+}
+
+// enqueueDue moves idle pollers whose next run has passed onto the queue and
+// returns the earliest upcoming run among the rest. Callers must hold pm.mu.
+func (pm *PollingManager) enqueueDue(now time.Time) time.Time {
+	var next time.Time
+	for _, poller := range pm.pollers {
+		if poller.state != pollerIdle {
+			continue
+		}
+		if !poller.nextRun.After(now) {
+			poller.state = pollerQueued
+			pm.queue = append(pm.queue, poller)
+			continue
+		}
+		if next.IsZero() || poller.nextRun.Before(next) {
+			next = poller.nextRun
+		}
+	}
+	return next
+}
+
+func (pm *PollingManager) work() {
+	for {
+		select {
+		case poller := <-pm.jobs:
+			pm.run(poller)
+		case <-pm.ctx.Done():
+			return
+		}
+	}
+}
+
+func (pm *PollingManager) run(poller *AppPoller) {
+	pm.mu.Lock()
+	if poller.stopped {
+		pm.mu.Unlock()
+		return
+	}
+	call, pending := pm.inFlight[poller.appID]
+	if pending && call.started {
+		// An on-demand poll got there first, so this slot is already covered
+		if poller.state == pollerQueued {
+			poller.state = pollerIdle
+			poller.nextRun = time.Now().Add(poller.effective)
+		}
+		pm.mu.Unlock()
+		return
+	}
+	if !pending {
+		call = pm.registerPoll(poller, false)
+	}
+	call.started = true
+	poller.state = pollerRunning
+	pm.running++
+	pm.mu.Unlock()
+
+	pm.execute(poller, call)
+
+	pm.mu.Lock()
+	pm.running--
+	pm.mu.Unlock()
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"context"
+	"errors"
+)
+
+// ReviewSource fetches an app's reviews from one store API. PollingManager
+// polls each app through the source its config names, or the default source
+// for its platform.
+type ReviewSource interface {
+	// Name is the models.Source value apps select this source by.
+	Name() string
+	// FetchWithRetry fetches an app's most recent reviews, retrying each
+	// failing request up to maxRetries times. The result is returned
+	// alongside errors too, so callers can record the attempts made.
+	FetchWithRetry(ctx context.Context, appID string, opts FetchOptions, maxRetries int) (*FetchResult, error)
+}
+
+// ErrNoSource is returned when polling an app whose source isn't configured.
+var ErrNoSource = errors.New("review source not configured")
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/models"
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+// storeAPI is the part of the store API sources that doesn't depend on the
+// store: a cached bearer token, authorised JSON requests and walking a paged
+// review listing with retries.
+type storeAPI struct {
+	client *http.Client
+	logger *logger.Logger
+	// name identifies the API in errors.
+	name string
+	// issueToken obtains a new bearer token and the time it expires.
+	issueToken func(ctx context.Context) (string, time.Time, error)
+	// Backoff between retries starts at retryBase and doubles up to retryMax.
+	retryBase time.Duration
+	retryMax  time.Duration
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func newStoreAPI(logger *logger.Logger, name string, issueToken func(ctx context.Context) (string, time.Time, error)) *storeAPI {
+	return &storeAPI{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		logger:     logger,
+		name:       name,
+		issueToken: issueToken,
+		retryBase:  time.Second,
+		retryMax:   30 * time.Second,
+	}
+}
+
+// apiPage is one converted page of a store API's review listing.
+type apiPage struct {
+	reviews     []models.Review
+	quarantined []models.QuarantinedEntry
+	// next locates the following page; empty on the last one.
+	next string
+}
+
+// walk fetches pages through fetchPage, starting at cursor, until a page has
+// no next cursor, maxPages have been fetched (no cap when zero) or, with
+// opts.Known set, every review on a page is known. Each page request is
+// retried up to maxRetries times.
+func (a *storeAPI) walk(ctx context.Context, result *FetchResult, opts FetchOptions, maxPages, maxRetries int, cursor string, fetchPage func(cursor string) (*apiPage, int, error)) error {
+	for page := 1; maxPages <= 0 || page <= maxPages; page++ {
+		var fetched *apiPage
+		err := retryRequest(ctx, a.logger, result, maxRetries, a.retryBase, a.retryMax, func() (int, error) {
+			var status int
+			var err error
+			fetched, status, err = fetchPage(cursor)
+			return status, err
+		})
+		if err != nil {
+			return fmt.Errorf("page %d: %w", page, err)
+		}
+		result.Pages++
+		result.Reviews = append(result.Reviews, fetched.reviews...)
+		result.Quarantined = append(result.Quarantined, fetched.quarantined...)
+
+		// A page can hold nothing storable, for instance when every review
+		// on it was quarantined, so only the cursor ends the walk
+		cursor = fetched.next
+		if cursor == "" {
+			return nil
+		}
+		if opts.Known != nil && len(fetched.reviews) > 0 && allKnown(a.logger, fetched.reviews, opts.Known) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// getJSON requests url with the bearer token and decodes the response into
+// v, returning the HTTP status whenever a response was received.
+func (a *storeAPI) getJSON(ctx context.Context, url string, v any) (int, error) {
+	token, err := a.bearerToken(ctx)
+	if err != nil {
+		return 0, notSent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, notSent(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch from %s: %w", a.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusUnauthorized {
+			// Let the next attempt use a fresh token
+			a.resetToken()
+		}
+		return resp.StatusCode, &FeedError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Source:     a.name,
+		}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to decode %s response: %w", a.name, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// bearerToken returns the cached token, issuing a new one when it is about
+// to expire.
+func (a *storeAPI) bearerToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Until(a.tokenExpiry) > time.Minute {
+		return a.token, nil
+	}
+
+	token, expiry, err := a.issueToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	a.token = token
+	a.tokenExpiry = expiry
+	return a.token, nil
+}
+
+func (a *storeAPI) resetToken() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = ""
+}
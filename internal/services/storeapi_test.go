@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/youthtrouble/symmetrical-giggle/internal/config"
+	"github.com/youthtrouble/symmetrical-giggle/internal/repository"
+	"github.com/youthtrouble/symmetrical-giggle/pkg/logger"
+)
+
+// fakeStoreAPI stands in for a store API's paged review listing at path.
+// Pages are picked by the cursorParam query parameter, "page-<n>", and NEXT
+// in a page is replaced with link(n+1) unless it is the last one. Requests
+// check rejects get a 401. Other endpoints can be added to mux.
+type fakeStoreAPI struct {
+	*httptest.Server
+	mux *http.ServeMux
+
+	mu     sync.Mutex
+	served []int
+}
+
+func newFakeStoreAPI(t *testing.T, path, cursorParam string, pages []string, check func(r *http.Request) error, link func(baseURL string, page int) string) *fakeStoreAPI {
+	t.Helper()
+
+	api := &fakeStoreAPI{mux: http.NewServeMux()}
+	api.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if err := check(r); err != nil {
+			t.Errorf("Rejected request: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		page := 0
+		if cursor := r.URL.Query().Get(cursorParam); cursor != "" {
+			fmt.Sscanf(cursor, "page-%d", &page)
+		}
+		api.mu.Lock()
+		api.served = append(api.served, page)
+		api.mu.Unlock()
+
+		body := pages[page]
+		if page+1 < len(pages) {
+			body = strings.Replace(body, "NEXT", link(api.URL, page+1), 1)
+		}
+		w.Write([]byte(body))
+	})
+	api.Server = httptest.NewServer(api.mux)
+	t.Cleanup(api.Close)
+	return api
+}
+
+// pagesServed returns the pages requested so far, in order, and forgets them.
+func (a *fakeStoreAPI) pagesServed() []int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	served := a.served
+	a.served = nil
+	return served
+}
+
+// newSourcePollTest returns an in-memory repository, a polling manager with
+// sources registered, and a poller for appID on platform through source, or
+// the platform's default source when empty.
+func newSourcePollTest(t *testing.T, appID, platform, source string, sources ...ReviewSource) (*repository.SQLiteRepository, *PollingManager, *AppPoller) {
+	t.Helper()
+
+	repo, err := repository.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test repository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	pm := NewPollingManager(repo, nil, config.PollingConfig{}, logger.New("error"))
+	for _, registered := range sources {
+		pm.AddSource(registered)
+	}
+
+	poller := &AppPoller{appID: appID, platform: platform, source: source, interval: time.Hour, circuit: newCircuitBreaker(appID)}
+	return repo, pm, poller
+}
+
+func TestStoreAPI_RenewsTokenAfterUnauthorized(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	issued := 0
+	api := newStoreAPI(logger.New("error"), "Test API", func(ctx context.Context) (string, time.Time, error) {
+		issued++
+		return fmt.Sprintf("token-%d", issued), time.Now().Add(time.Hour), nil
+	})
+
+	var body struct {
+		OK bool `json:"ok"`
+	}
+	if _, err := api.getJSON(context.Background(), server.URL, &body); err == nil || !strings.Contains(err.Error(), "Test API returned status 401") {
+		t.Fatalf("Expected a 401, got %v", err)
+	}
+	if _, err := api.getJSON(context.Background(), server.URL, &body); err != nil || !body.OK {
+		t.Fatalf("Expected the request to succeed with a fresh token, got %v", err)
+	}
+
+	// The fresh token is cached
+	if _, err := api.getJSON(context.Background(), server.URL, &body); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if issued != 2 || requests != 3 {
+		t.Errorf("Expected 2 tokens issued for 3 requests, got %d for %d", issued, requests)
+	}
+}